@@ -5,8 +5,14 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/siddhartharajbongshi/spendsense-backend/models"
 	"github.com/siddhartharajbongshi/spendsense-backend/services"
@@ -16,17 +22,26 @@ var (
 	parser      = services.NewParserService()
 	categorizer = services.NewCategorizerService()
 	insightGen  = services.NewInsightService()
+	dedupe      = services.NewDedupeService()
+	transfers   = services.NewTransferService()
+	refunds     = services.NewRefundService()
+	merchants   = services.NewMerchantService()
+	fx          = services.NewFXService()
 	// Default to "llama3", user can change or we can make it an env var
 	tutor = services.NewLLMService("tinyllama")
 
 	// In-memory storage for demo
-	userExpenses = make(map[string][]models.Expense)
+	userExpenses  = make(map[string][]models.Expense)
+	userSettings  = make(map[string]models.Settings)
+	userOverrides = make(map[string]services.MerchantOverrides)
 )
 
+const maxUploadSize = 100 << 20 // 100 MB
+
 func enableCors(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:5173") // SvelteKit default port
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == "OPTIONS" {
@@ -38,13 +53,45 @@ func enableCors(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// settingsFor returns a user's settings, with the defaults filled in.
+func settingsFor(userID string) models.Settings {
+	settings := userSettings[userID]
+	if settings.BaseCurrency == "" {
+		settings.BaseCurrency = services.DefaultBaseCurrency
+	}
+	return settings
+}
+
 func main() {
+	// Exchange rates are read from a local file; see FXService.Load for the layout
+	if path := os.Getenv("FX_RATES_FILE"); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("Loading FX rates: %v", err)
+		}
+		n, err := fx.Load(file, true)
+		file.Close()
+		if err != nil {
+			log.Fatalf("Loading FX rates from %s: %v", path, err)
+		}
+		fmt.Printf("Loaded %d FX rates from %s\n", n, path)
+	}
+
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/health", enableCors(handleHealth))
 	mux.HandleFunc("/upload", enableCors(handleUpload))
+	mux.HandleFunc("/preview", enableCors(handlePreview))
+	mux.HandleFunc("/templates", enableCors(handleTemplates))
+	mux.HandleFunc("/fx-rates", enableCors(handleFXRates))
+	mux.HandleFunc("/settings", enableCors(handleSettings))
+	mux.HandleFunc("/rules", enableCors(handleRules))
+	mux.HandleFunc("/categories", enableCors(handleCategories))
+	mux.HandleFunc("/recategorize", enableCors(handleRecategorize))
+	mux.HandleFunc("/merchant-overrides", enableCors(handleMerchantOverrides))
 	mux.HandleFunc("/sample-data", enableCors(handleSampleData))
 	mux.HandleFunc("/dashboard", enableCors(handleDashboard))
+	mux.HandleFunc("/export", enableCors(handleExport))
 	mux.HandleFunc("/explain-insight", enableCors(handleExplainInsight))
 	mux.HandleFunc("/generate-persona", enableCors(handleGeneratePersona))
 
@@ -63,62 +110,564 @@ func handleUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// file, handler, err := r.FormFile("file") // Does not work with ParseMultipartForm not called?
-	// ParseMultipartForm is called by FormFile automatically usually, but let's be safe
-	r.ParseMultipartForm(10 << 20) // 10 MB
+	// "replace" (the default) swaps out earlier data; "merge" adds only new transactions
+	mode := r.URL.Query().Get("mode")
+	if mode != "" && mode != "replace" && mode != "merge" {
+		http.Error(w, "Unsupported mode: "+mode, http.StatusBadRequest)
+		return
+	}
 
-	file, _, err := r.FormFile("file")
-	if err != nil {
+	// Stream each file part straight into the parser instead of buffering it
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	userID := "default"
+	base := settingsFor(userID).BaseCurrency
+	var stored []models.Expense
+	if mode == "merge" {
+		stored = userExpenses[userID]
+	}
+	var summary models.MergeSummary
+	var reports []*models.ParseReport
+	var parseErr error
+	err := eachFilePart(r, func(file *multipart.Part, fields url.Values) error {
+		// Parse
+		opts := parseOptions(r)
+		if raw := fields.Get("mapping"); raw != "" {
+			opts.Mapping = &models.ColumnMapping{}
+			if err := json.Unmarshal([]byte(raw), opts.Mapping); err != nil {
+				parseErr = fmt.Errorf("%s: invalid mapping: %v", file.FileName(), err)
+				return parseErr
+			}
+		}
+		// The statement's currency, for rows that do not name theirs
+		currency := base
+		if raw := fields.Get("currency"); raw != "" {
+			c, err := services.NormalizeCurrency(raw)
+			if err != nil {
+				parseErr = fmt.Errorf("%s: %v", file.FileName(), err)
+				return parseErr
+			}
+			currency = c
+		}
+		expenses, report, err := parser.ParseReader(r.Context(), file, opts)
+		if err != nil {
+			parseErr = fmt.Errorf("%s: %v", file.FileName(), err)
+			return parseErr
+		}
+		report.File = file.FileName()
+		tagAccount(expenses, report, strings.TrimSpace(fields.Get("account")))
+		reports = append(reports, report)
+
+		// Restate amounts in the base currency; rows without a rate are reported
+		expenses = fx.ConvertImport(expenses, report, currency, base)
+
+		// Normalize merchants, then categorize on them
+		expenses = merchants.NormalizeExpenses(expenses)
+		expenses = categorizer.CategorizeExpenses(expenses, userOverrides[userID])
+
+		// Merge into what this upload has collected so far
+		var added models.MergeSummary
+		stored, added = dedupe.Merge(stored, expenses)
+		summary.Added += added.Added
+		summary.DuplicatesSkipped += added.DuplicatesSkipped
+		summary.Total = added.Total
+		return nil
+	})
+	if parseErr != nil {
+		http.Error(w, fmt.Sprintf("Parsing failed: %v", parseErr), http.StatusBadRequest)
+		return
+	}
+	if err != nil || reports == nil {
 		http.Error(w, "Failed to get file", http.StatusBadRequest)
 		return
 	}
-	defer file.Close()
 
-	// Save to temp file to reuse our parser logic
-	tempFile, err := os.CreateTemp("", "upload-*.csv")
+	// Card bill payments and self-transfers only move money between accounts
+	transfers.Match(stored)
+	// Refunds are netted out of the category of the purchase they return
+	refunds.Match(stored)
+
+	// Store
+	userExpenses[userID] = stored
+
+	// Generate Response
+	dashboard := insightGen.GenerateDashboardData(stored, base)
+	dashboard.ParseReports = reports
+	if len(reports) == 1 {
+		dashboard.ParseReport = reports[0]
+	}
+	if mode == "merge" || len(reports) > 1 {
+		dashboard.Merge = &summary
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dashboard)
+}
+
+// tagAccount makes sure every expense names the account it came from. An account
+// given with the upload replaces what the statement says; otherwise untagged
+// expenses are labelled with the file name.
+func tagAccount(expenses []models.Expense, report *models.ParseReport, account string) {
+	if account != "" {
+		for i := range expenses {
+			expenses[i].Account = account
+		}
+		report.Accounts = []string{account}
+		return
+	}
+	if report.File == "" {
+		return
+	}
+	for i := range expenses {
+		if expenses[i].Account == "" {
+			expenses[i].Account = report.File
+			if !slices.Contains(report.Accounts, report.File) {
+				report.Accounts = append(report.Accounts, report.File)
+			}
+		}
+	}
+}
+
+// parseOptions reads the import options shared by /upload and /preview from the query.
+func parseOptions(r *http.Request) services.ParseOptions {
+	query := r.URL.Query()
+	return services.ParseOptions{
+		Format:     query.Get("format"),
+		Profile:    query.Get("bank"),
+		Sheet:      query.Get("sheet"),
+		Locale:     query.Get("locale"),
+		DateFormat: query.Get("date_format"),
+		Encoding:   query.Get("encoding"),
+		Delimiter:  query.Get("delimiter"),
+		Template:   query.Get("template"),
+	}
+}
+
+// maxFieldSize bounds the form fields sent alongside an upload.
+const maxFieldSize = 64 << 10
+
+// nextFilePart returns the "file" part of a multipart upload without reading it into memory,
+// along with any form fields sent before it.
+func nextFilePart(r *http.Request) (*multipart.Part, url.Values, error) {
+	mr, err := r.MultipartReader()
 	if err != nil {
-		http.Error(w, "Server error", http.StatusInternalServerError)
+		return nil, nil, err
+	}
+	return nextFile(mr)
+}
+
+// eachFilePart calls fn with every "file" part of a multipart upload in turn, along with
+// the form fields sent between the previous file and this one.
+func eachFilePart(r *http.Request, fn func(*multipart.Part, url.Values) error) error {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+	for {
+		part, fields, err := nextFile(mr)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = fn(part, fields)
+		part.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func nextFile(mr *multipart.Reader) (*multipart.Part, url.Values, error) {
+	fields := make(url.Values)
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return nil, nil, err
+		}
+		if part.FormName() == "file" {
+			return part, fields, nil
+		}
+		value, err := io.ReadAll(io.LimitReader(part, maxFieldSize))
+		part.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		fields.Add(part.FormName(), string(value))
+	}
+}
+
+func handlePreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	defer os.Remove(tempFile.Name())
 
-	fileBytes, err := io.ReadAll(file)
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	file, _, err := nextFilePart(r)
 	if err != nil {
-		http.Error(w, "Failed to read file", http.StatusInternalServerError)
+		http.Error(w, "Failed to get file", http.StatusBadRequest)
 		return
 	}
-	tempFile.Write(fileBytes)
-	tempFile.Close()
+	defer file.Close()
 
-	// Parse
-	expenses, err := parser.ParseCSV(tempFile.Name())
+	preview, err := parser.Preview(r.Context(), file, parseOptions(r))
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Parsing failed: %v", err), http.StatusBadRequest)
+		http.Error(w, fmt.Sprintf("Preview failed: %v", err), http.StatusBadRequest)
 		return
 	}
 
-	// Categorize
-	expenses = categorizer.CategorizeExpenses(expenses)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
 
-	// Store
+type TemplateRequest struct {
+	Name    string               `json:"name"`
+	Headers []string             `json:"headers"` // Header row from /preview
+	Mapping models.ColumnMapping `json:"mapping"`
+}
+
+func handleTemplates(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(parser.Templates())
+	case "POST":
+		var req TemplateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		template, err := parser.SaveTemplate(req.Name, req.Headers, req.Mapping)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(template)
+	case "DELETE":
+		if !parser.DeleteTemplate(r.URL.Query().Get("name")) {
+			http.Error(w, "Template not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleFXRates lists the loaded exchange rates (GET) or loads a CSV or JSON
+// rate table (POST, multipart "file"). Uploaded rates replace the loaded ones
+// unless mode=merge. Transactions are converted when they are imported, so
+// files left short of rates have to be uploaded again.
+func handleFXRates(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.FXTable{Pairs: fx.Pairs()})
+	case "POST":
+		mode := r.URL.Query().Get("mode")
+		if mode != "" && mode != "replace" && mode != "merge" {
+			http.Error(w, "Unsupported mode: "+mode, http.StatusBadRequest)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+		file, _, err := nextFilePart(r)
+		if err != nil {
+			http.Error(w, "Failed to get file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		n, err := fx.Load(file, mode != "merge")
+		if err != nil {
+			http.Error(w, "Loading rates failed: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.FXTable{Loaded: n, Pairs: fx.Pairs()})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSettings returns (GET) or updates (POST) the user's settings. Changing
+// the base currency restates the stored transactions in it, from their
+// original amounts; it fails without changing anything when a rate is missing.
+func handleSettings(w http.ResponseWriter, r *http.Request) {
 	userID := "default"
-	userExpenses[userID] = expenses
+	switch r.Method {
+	case "GET":
+	case "POST":
+		var req models.Settings
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		settings := settingsFor(userID)
+		if req.BaseCurrency != "" {
+			base, err := services.NormalizeCurrency(req.BaseCurrency)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if expenses := userExpenses[userID]; base != settings.BaseCurrency && expenses != nil {
+				if err := fx.ConvertExpenses(expenses, base); err != nil {
+					http.Error(w, "Cannot switch to "+base+": "+err.Error(), http.StatusBadRequest)
+					return
+				}
+				// Amounts moved with the rates, so pairs may now line up differently
+				transfers.Match(expenses)
+				refunds.Match(expenses)
+			}
+			settings.BaseCurrency = base
+		}
+		userSettings[userID] = settings
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-	// Generate Response
-	dashboard := insightGen.GenerateDashboardData(expenses)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settingsFor(userID))
+}
+
+// handleRules lists the categorization rules (GET), adds or replaces one by ID
+// (POST) or removes one (DELETE, ?id=). Changes are applied to the stored
+// transactions straight away.
+func handleRules(w http.ResponseWriter, r *http.Request) {
+	userID := "default"
+	switch r.Method {
+	case "GET":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(categorizer.Rules())
+	case "POST":
+		var req models.CategoryRule
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		rule, err := categorizer.SaveRule(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		recategorize(userID)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rule)
+	case "DELETE":
+		if !categorizer.DeleteRule(r.URL.Query().Get("id")) {
+			http.Error(w, "Rule not found", http.StatusNotFound)
+			return
+		}
+		recategorize(userID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+type CategoryRequest struct {
+	Name string `json:"name"`
+}
+
+// handleCategories lists the spending categories (GET), adds a custom one
+// (POST) or removes one no rule uses (DELETE, ?name=).
+func handleCategories(w http.ResponseWriter, r *http.Request) {
+	userID := "default"
+	switch r.Method {
+	case "GET":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(categorizer.Categories())
+	case "POST":
+		var req CategoryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		category, err := categorizer.AddCategory(req.Name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(category)
+	case "DELETE":
+		found, err := categorizer.DeleteCategory(r.URL.Query().Get("name"))
+		if !found {
+			http.Error(w, "Category not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		recategorize(userID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+type RecategorizeRequest struct {
+	ID       string `json:"id"` // Of the stored transaction being fixed
+	Category string `json:"category"`
+	Scope    string `json:"scope,omitempty"` // "transaction" (the default) or "merchant"
+}
+
+// handleRecategorize moves a stored transaction to another category. With
+// scope "merchant" every spend at the same merchant moves too, and the choice
+// is saved as a merchant override for future uploads.
+func handleRecategorize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RecategorizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Scope != "" && req.Scope != "transaction" && req.Scope != "merchant" {
+		http.Error(w, "Unsupported scope: "+req.Scope, http.StatusBadRequest)
+		return
+	}
+	category, ok := categorizer.Category(req.Category)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown category %q", req.Category), http.StatusBadRequest)
+		return
+	}
+
+	userID := "default"
+	expenses, exists := userExpenses[userID]
+	if !exists {
+		http.Error(w, "No data uploaded", http.StatusNotFound)
+		return
+	}
+	i := slices.IndexFunc(expenses, func(e models.Expense) bool {
+		return req.ID != "" && e.ID == req.ID
+	})
+	if i < 0 {
+		http.Error(w, "Transaction not found", http.StatusNotFound)
+		return
+	}
+	target := expenses[i]
+	if target.IsCredit() {
+		http.Error(w, "Only spending can be recategorized; credits count as income or follow the purchase they refund", http.StatusBadRequest)
+		return
+	}
+
+	result := models.RecategorizeResult{Category: category}
+	rule := services.RuleManual
+	affected := func(e models.Expense) bool { return e.ID == target.ID }
+	if req.Scope == "merchant" {
+		if userOverrides[userID] == nil {
+			userOverrides[userID] = make(services.MerchantOverrides)
+		}
+		result.Merchant = userOverrides[userID].Set(target, category).Merchant
+		rule = services.RuleMerchant
+		affected = func(e models.Expense) bool { return services.SameMerchant(e, target) }
+	}
+	for i := range expenses {
+		e := &expenses[i]
+		if e.IsCredit() || !affected(*e) {
+			continue
+		}
+		if e.Category != category {
+			result.Updated++
+		}
+		e.Category, e.CategoryRule = category, rule
+	}
+	// Refunds follow their purchases into the new category
+	refunds.Match(expenses)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(dashboard)
+	json.NewEncoder(w).Encode(result)
 }
 
+// handleMerchantOverrides lists the user's merchant overrides (GET) or removes
+// one (DELETE, ?merchant=), handing its transactions back to the rules.
+func handleMerchantOverrides(w http.ResponseWriter, r *http.Request) {
+	userID := "default"
+	switch r.Method {
+	case "GET":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(userOverrides[userID].List())
+	case "DELETE":
+		if !userOverrides[userID].Delete(r.URL.Query().Get("merchant")) {
+			http.Error(w, "Override not found", http.StatusNotFound)
+			return
+		}
+		recategorize(userID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// recategorize applies changed rules and overrides to a user's stored
+// transactions, so the next dashboard reflects them.
+func recategorize(userID string) {
+	expenses := userExpenses[userID]
+	if expenses == nil {
+		return
+	}
+	categorizer.Recategorize(expenses, userOverrides[userID])
+	// Refunds follow their purchases into the new categories
+	refunds.Match(expenses)
+}
+
+// handleSampleData generates demo data. Query parameters: persona ("student",
+// "young_professional", "family"), months (1-24), seed, and end (YYYY-MM, the
+// last month; defaults to last month).
 func handleSampleData(w http.ResponseWriter, r *http.Request) {
-	expenses := parser.GenerateSampleData()
-	expenses = categorizer.CategorizeExpenses(expenses)
+	query := r.URL.Query()
+	opts := services.SampleOptions{Persona: query.Get("persona")}
+	if raw := query.Get("months"); raw != "" {
+		months, err := strconv.Atoi(raw)
+		if err != nil {
+			http.Error(w, "Invalid months: "+raw, http.StatusBadRequest)
+			return
+		}
+		opts.Months = months
+	}
+	if raw := query.Get("seed"); raw != "" {
+		seed, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid seed: "+raw, http.StatusBadRequest)
+			return
+		}
+		opts.Seed = seed
+	}
+	if raw := query.Get("end"); raw != "" {
+		end, err := time.Parse("2006-01", raw)
+		if err != nil {
+			http.Error(w, "Invalid end month, want YYYY-MM: "+raw, http.StatusBadRequest)
+			return
+		}
+		opts.End = end
+	}
 
+	expenses, err := parser.GenerateSampleData(opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	userID := "default"
+	base := settingsFor(userID).BaseCurrency
+	if err := fx.ConvertExpenses(expenses, base); err != nil {
+		http.Error(w, "Sample data is in INR: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	expenses = merchants.NormalizeExpenses(expenses)
+	expenses = categorizer.CategorizeExpenses(expenses, userOverrides[userID])
+	transfers.Match(expenses)
+	refunds.Match(expenses)
+
 	userExpenses[userID] = expenses
 
-	dashboard := insightGen.GenerateDashboardData(expenses)
+	dashboard := insightGen.GenerateDashboardData(expenses, base)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(dashboard)
 }
@@ -131,11 +680,29 @@ func handleDashboard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dashboard := insightGen.GenerateDashboardData(expenses)
+	dashboard := insightGen.GenerateDashboardData(expenses, settingsFor(userID).BaseCurrency)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(dashboard)
 }
 
+func handleExport(w http.ResponseWriter, r *http.Request) {
+	userID := "default"
+	expenses, exists := userExpenses[userID]
+	if !exists {
+		http.Error(w, "No data uploaded", http.StatusNotFound)
+		return
+	}
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "qif":
+		w.Header().Set("Content-Type", "application/qif")
+		w.Header().Set("Content-Disposition", `attachment; filename="spendsense.qif"`)
+		categorizer.WriteQIF(w, expenses)
+	default:
+		http.Error(w, "Unsupported export format: "+format, http.StatusBadRequest)
+	}
+}
+
 type ExplainRequest struct {
 	Insight  models.Insight `json:"insight"`
 	FollowUp string         `json:"follow_up"`
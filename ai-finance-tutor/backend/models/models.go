@@ -1,10 +1,104 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
+// Transaction kinds. Amount is always positive; Kind says which way it moved.
+const (
+	KindDebit  = "debit"  // Money out: a spend
+	KindCredit = "credit" // Money in: salary, refunds, transfers in
+)
+
 type Expense struct {
-	Date        string  `json:"date"`
-	Description string  `json:"description"`
-	Amount      float64 `json:"amount"`
-	Category    string  `json:"category"`
+	Date           time.Time `json:"date"` // Calendar date, plus the time of day when the source gave one
+	Description    string    `json:"description"`
+	Amount         float64   `json:"amount"` // In the base currency
+	Kind           string    `json:"kind"`   // KindDebit or KindCredit; empty means debit
+	Category       string    `json:"category"`
+	TransactionID  string    `json:"transaction_id,omitempty"`  // Bank-issued ID, e.g. OFX FITID
+	Account        string    `json:"account,omitempty"`         // Source account hint, e.g. "HDFC XX1234"
+	Currency       string    `json:"currency,omitempty"`        // ISO code of the amount the statement gave, e.g. "USD"
+	OriginalAmount float64   `json:"original_amount,omitempty"` // Amount in Currency when that is not the base currency
+	Merchant       string    `json:"merchant,omitempty"`        // Clean name of the other party, e.g. "Zomato"
+	Rail           string    `json:"rail,omitempty"`            // Payment rail: UPI, POS, NEFT, RTGS, IMPS, ATM or NACH
+	VPA            string    `json:"vpa,omitempty"`             // UPI address, e.g. "zomato.payu@hdfcbank"
+	Reference      string    `json:"reference,omitempty"`       // Bank reference from the narration, e.g. the UPI RRN
+	Fingerprint    string    `json:"fingerprint,omitempty"`     // Recognises the transaction across uploads; identical ones share it
+	ID             string    `json:"id,omitempty"`              // Unique among the stored transactions: the fingerprint and an occurrence number
+	TransferOf     string    `json:"transfer_of,omitempty"`     // ID of the other leg when money moved between own accounts
+	RefundOf       string    `json:"refund_of,omitempty"`       // ID of the purchase a credit refunds
+	CategoryRule   string    `json:"category_rule,omitempty"`   // ID of the rule that set Category
+}
+
+// Layouts of the "date" and "timestamp" fields in JSON. Timestamps keep the
+// clock time the source wrote, without a zone.
+const (
+	DateLayout      = "2006-01-02"
+	TimestampLayout = "2006-01-02T15:04:05"
+)
+
+// Day is the calendar date as YYYY-MM-DD.
+func (e Expense) Day() string {
+	return e.Date.Format(DateLayout)
+}
+
+// HasTime reports whether Date carries a time of day. Midnight counts as none:
+// it is what exports write when they only know the date.
+func (e Expense) HasTime() bool {
+	h, m, sec := e.Date.Clock()
+	return h != 0 || m != 0 || sec != 0
+}
+
+// expenseJSON is how an Expense is written: the date as YYYY-MM-DD and, when
+// the source gave one, the full timestamp next to it.
+type expenseJSON struct {
+	plainExpense
+	Date      string `json:"date"`
+	Timestamp string `json:"timestamp,omitempty"`
+}
+
+type plainExpense Expense
+
+func (e Expense) MarshalJSON() ([]byte, error) {
+	out := expenseJSON{plainExpense: plainExpense(e), Date: e.Day()}
+	if e.HasTime() {
+		out.Timestamp = e.Date.Format(TimestampLayout)
+	}
+	return json.Marshal(out)
+}
+
+func (e *Expense) UnmarshalJSON(data []byte) error {
+	var in expenseJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*e = Expense(in.plainExpense)
+	var err error
+	if in.Timestamp != "" {
+		e.Date, err = time.Parse(TimestampLayout, in.Timestamp)
+	} else {
+		e.Date, err = time.Parse(DateLayout, in.Date)
+	}
+	return err
+}
+
+// IsCredit reports whether the transaction brought money in.
+func (e Expense) IsCredit() bool {
+	return e.Kind == KindCredit
+}
+
+// IsRefund reports whether the transaction is a credit linked to the purchase
+// it refunds.
+func (e Expense) IsRefund() bool {
+	return e.RefundOf != ""
+}
+
+// IsTransfer reports whether the transaction is one leg of a transfer between
+// the user's own accounts, such as a credit card bill paid from savings.
+func (e Expense) IsTransfer() bool {
+	return e.TransferOf != ""
 }
 
 type Insight struct {
@@ -19,13 +113,154 @@ type Insight struct {
 }
 
 type DashboardData struct {
+	BaseCurrency     string             `json:"base_currency"` // ISO code every total is reported in
 	TotalExpenses    float64            `json:"total_expenses"`
-	ExpenseCount     int                `json:"expense_count"`
+	TotalIncome      float64            `json:"total_income"`
+	NetCashFlow      float64            `json:"net_cash_flow"`               // Income minus spend
+	SavingsRate      float64            `json:"savings_rate,omitempty"`      // Percent of income kept; unset without income
+	ExpenseCount     int                `json:"expense_count"`               // Debits only
+	TransferCount    int                `json:"transfer_count,omitempty"`    // Transfer legs left out of every total
+	RefundsNetted    float64            `json:"refunds_netted,omitempty"`    // Refunds subtracted from the spend of their purchases
+	UnmatchedRefunds []Expense          `json:"unmatched_refunds,omitempty"` // Credits that read like refunds but match no purchase; counted as income
 	AverageDaily     float64            `json:"average_daily"`
-	Expenses         []Expense          `json:"expenses"`
+	Expenses         []Expense          `json:"expenses"` // Debits and credits
 	Insights         []Insight          `json:"insights"`
 	MonthlyBreakdown map[string]float64 `json:"monthly_breakdown"`
-	ConfidenceScore  int                `json:"confidence_score"` // 0-100 Financial Health Score
+	AccountBreakdown map[string]float64 `json:"account_breakdown,omitempty"` // Spend per source account
+	ConfidenceScore  int                `json:"confidence_score"`            // 0-100 Financial Health Score
+	ParseReport      *ParseReport       `json:"parse_report,omitempty"`      // Set when one file was uploaded
+	ParseReports     []*ParseReport     `json:"parse_reports,omitempty"`     // One per uploaded file
+	Merge            *MergeSummary      `json:"merge,omitempty"`             // Set when an upload was merged into earlier data
+}
+
+// CategoryRule puts transactions whose description matches into Category. It
+// matches on any of Keywords, as whole words, or on Pattern, a regular
+// expression; both ignore case. MinAmount and MaxAmount, when set, limit it to
+// amounts in that range. When several rules match, the highest Priority wins,
+// then the longest match, then the rule listed first.
+type CategoryRule struct {
+	ID        string   `json:"id"`
+	Category  string   `json:"category"`
+	Priority  int      `json:"priority"`
+	Keywords  []string `json:"keywords,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
+	MinAmount float64  `json:"min_amount,omitempty"` // In the base currency
+	MaxAmount float64  `json:"max_amount,omitempty"`
+}
+
+// Category is a spending category transactions can be put in.
+type Category struct {
+	Name   string `json:"name"`
+	Custom bool   `json:"custom,omitempty"` // Added by the user rather than built in
+	Rules  int    `json:"rules"`            // Rules that assign it
+}
+
+// MerchantOverride files every spend at a merchant under Category, ahead of
+// the categorization rules. It is saved when the user recategorizes a
+// transaction for its whole merchant.
+type MerchantOverride struct {
+	Merchant string `json:"merchant"`
+	Category string `json:"category"`
+}
+
+// RecategorizeResult says what a manual recategorization changed.
+type RecategorizeResult struct {
+	Category string `json:"category"`
+	Merchant string `json:"merchant,omitempty"` // Set when the choice was saved for the merchant
+	Updated  int    `json:"updated"`            // Stored transactions whose category changed
+}
+
+// MergeSummary counts what merging an upload into the stored transactions did.
+type MergeSummary struct {
+	Added             int `json:"added"`
+	DuplicatesSkipped int `json:"duplicates_skipped"`
+	Total             int `json:"total"` // Transactions stored after the merge
+}
+
+// FXPair describes the rates loaded for one currency pair: one unit of
+// Currency is worth the rate in Base.
+type FXPair struct {
+	Currency string `json:"currency"`
+	Base     string `json:"base"`
+	Rates    int    `json:"rates"`
+	From     string `json:"from"` // Date of the first rate
+	To       string `json:"to"`   // Date of the last rate
+}
+
+// FXTable lists the loaded exchange rates.
+type FXTable struct {
+	Loaded int      `json:"loaded,omitempty"` // Rates read from the file just uploaded
+	Pairs  []FXPair `json:"pairs"`
+}
+
+// Settings are the user's preferences.
+type Settings struct {
+	BaseCurrency string `json:"base_currency"` // ISO code insights are reported in, e.g. "INR"
+}
+
+// ParseReport summarizes what an import did with every row of the file.
+type ParseReport struct {
+	File             string         `json:"file,omitempty"` // Name of the uploaded file
+	Format           string         `json:"format"`
+	Encoding         string         `json:"encoding,omitempty"`          // e.g. "utf-8", "utf-16le", "windows-1252"
+	Delimiter        string         `json:"delimiter,omitempty"`         // CSV only: ",", ";", "tab" or "|"
+	Quoted           bool           `json:"quoted,omitempty"`            // CSV only: fields were wrapped in double quotes
+	Profile          string         `json:"profile,omitempty"`           // Detected bank layout, e.g. "hdfc"
+	Template         string         `json:"template,omitempty"`          // Saved mapping template that was applied
+	Accounts         []string       `json:"accounts,omitempty"`          // Source accounts the transactions were tagged with
+	DateFormat       string         `json:"date_format,omitempty"`       // e.g. "DD/MM/YYYY"
+	DateAlternatives []string       `json:"date_alternatives,omitempty"` // Other layouts that fit every date; set when ambiguous
+	RowsRead         int            `json:"rows_read"`
+	RowsImported     int            `json:"rows_imported"`
+	Skipped          map[string]int `json:"skipped"` // Reason -> count
+	Issues           []ParseIssue   `json:"issues"`
+	IssuesTruncated  bool           `json:"issues_truncated,omitempty"`
+}
+
+type ParseIssue struct {
+	Line    int    `json:"line"`
+	Level   string `json:"level"`  // "warning" or "error"
+	Reason  string `json:"reason"` // e.g. "invalid_amount"
+	Message string `json:"message"`
+}
+
+// ColumnMapping names the header of the column holding each field. Amount, or
+// Debit and/or Credit, must be set; the other optional fields may be empty.
+type ColumnMapping struct {
+	Date        string `json:"date"`
+	Description string `json:"description"`
+	Amount      string `json:"amount,omitempty"` // Signed, debits positive
+	Debit       string `json:"debit,omitempty"`
+	Credit      string `json:"credit,omitempty"`
+	Category    string `json:"category,omitempty"`
+	Currency    string `json:"currency,omitempty"`    // ISO code per row, e.g. "USD"
+	Time        string `json:"time,omitempty"`        // Time of day, when not part of the date
+	DateFormat  string `json:"date_format,omitempty"` // e.g. "DD/MM/YYYY"
+}
+
+// MappingTemplate is a saved ColumnMapping, applied automatically to files whose
+// header row has the same signature.
+type MappingTemplate struct {
+	Name      string        `json:"name"`
+	Signature string        `json:"signature"`
+	Headers   []string      `json:"headers"`
+	Mapping   ColumnMapping `json:"mapping"`
+}
+
+// FilePreview shows the start of a tabular upload so its columns can be mapped.
+type FilePreview struct {
+	Format     string         `json:"format"`
+	Encoding   string         `json:"encoding,omitempty"`
+	Delimiter  string         `json:"delimiter,omitempty"`
+	Sheet      string         `json:"sheet,omitempty"`
+	HeaderLine int            `json:"header_line"` // Line (or sheet row) of Headers
+	Headers    []string       `json:"headers"`
+	Signature  string         `json:"signature"`
+	Rows       [][]string     `json:"rows"` // First rows after the header
+	Profile    string         `json:"profile,omitempty"`
+	Template   string         `json:"template,omitempty"`
+	Mapping    *ColumnMapping `json:"mapping,omitempty"` // Current mapping; unset when the header is not recognised
+	Error      string         `json:"error,omitempty"`   // Why an upload would fail without a mapping
 }
 
 type TutorRequest struct {
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+)
+
+// preambleAccount finds the account or card number printed above the header
+// row of a bank export, e.g. "Account No : 50100012345678".
+var preambleAccount = regexp.MustCompile(`(?i)\b(?:a/c|account|acct|card)\s*(?:no\.?|number|#)?\s*[:.\-]?\s*([x*\d][x*\d ]{2,}\d)\b`)
+
+// accountLabel names an account the way alerts do: the institution followed by
+// the last four digits, e.g. "HDFC XX5678". Either part may be empty.
+func accountLabel(institution, number string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, number)
+	if len(digits) > 4 {
+		digits = digits[len(digits)-4:]
+	}
+	if digits == "" {
+		// IBANs and other identifiers without digits at the end are shown as is.
+		digits = strings.TrimSpace(number)
+	} else {
+		digits = "XX" + digits
+	}
+	return strings.TrimSpace(institution + " " + digits)
+}
+
+// statementAccount labels the account a CSV or XLSX export belongs to from its
+// preamble, falling back to the bank of the profile.
+func statementAccount(profile StatementProfile, preamble string) string {
+	if m := preambleAccount.FindStringSubmatch(preamble); m != nil {
+		return accountLabel(profile.Bank, m[1])
+	}
+	return profile.Bank
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+// parsedAmount is a money value read from statement text.
+type parsedAmount struct {
+	Value    float64 // Signed as written: "-450" and "(450)" are negative
+	Debit    bool    // Carried an explicit Dr/Debit marker
+	Credit   bool    // Carried an explicit Cr/Credit marker
+	Currency string  // ISO code when a symbol or code was present
+}
+
+// currencySymbols maps the symbols and codes seen in Indian and international
+// statements to ISO codes. Longer forms come first so "US$" wins over "$".
+var currencySymbols = []struct{ symbol, code string }{
+	{"rs.", "INR"}, {"rs", "INR"}, {"inr", "INR"}, {"₹", "INR"},
+	{"us$", "USD"}, {"usd", "USD"}, {"$", "USD"},
+	{"eur", "EUR"}, {"€", "EUR"},
+	{"gbp", "GBP"}, {"£", "GBP"},
+	{"aed", "AED"}, {"sgd", "SGD"}, {"jpy", "JPY"}, {"¥", "JPY"},
+}
+
+var (
+	currencyCode = regexp.MustCompile(`^[A-Za-z]{3}$`)
+	amountMarker = regexp.MustCompile(`(?i)^(?:(dr|cr|debit|credit)\.?\s+)?(.*?)(?:\s*(dr|cr|debit|credit)\.?)?$`)
+	amountDigits = regexp.MustCompile(`^[0-9.,' \x{00a0}\x{202f}]+$`)
+)
+
+// commaDecimalLocales are the locale languages that write 1.234,56.
+var commaDecimalLocales = map[string]bool{
+	"de": true, "fr": true, "es": true, "it": true, "nl": true, "pt": true,
+	"ru": true, "tr": true, "id": true, "da": true, "sv": true, "nb": true, "pl": true,
+}
+
+// decimalMark returns the decimal separator for a locale such as "en-IN" or
+// "de-DE", or 0 when the locale is empty and the mark should be guessed.
+func decimalMark(locale string) rune {
+	parts := strings.FieldsFunc(locale, func(r rune) bool { return r == '-' || r == '_' })
+	if len(parts) == 0 {
+		return 0
+	}
+	if commaDecimalLocales[strings.ToLower(parts[0])] {
+		return ','
+	}
+	return '.'
+}
+
+// parseMoney reads amounts like "₹1,23,456.00", "(450.00)", "450.00 Dr",
+// "Rs. 99", "USD 12.50" or "1.234,56". Digit grouping (Indian lakh, western
+// thousands, spaces, apostrophes) is ignored; the decimal mark comes from the
+// locale, or is guessed from the value when locale is empty.
+func parseMoney(s, locale string) (parsedAmount, error) {
+	var amt parsedAmount
+	raw := s
+	s = strings.TrimSpace(s)
+
+	if m := amountMarker.FindStringSubmatch(s); m != nil {
+		marker := strings.ToLower(m[1] + m[3])
+		amt.Debit = strings.HasPrefix(marker, "d")
+		amt.Credit = strings.HasPrefix(marker, "c")
+		s = strings.TrimSpace(m[2])
+	}
+
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	// Signs may sit on either side of the currency symbol: "-₹450", "₹-450", "450-".
+	for i := 0; i < 2; i++ {
+		if strings.HasPrefix(s, "-") || strings.HasSuffix(s, "-") {
+			negative = !negative
+			s = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(s, "-"), "-"))
+		}
+		s = strings.TrimSpace(strings.TrimPrefix(s, "+"))
+		s, amt.Currency = stripCurrency(s, amt.Currency)
+	}
+
+	if s == "" || !amountDigits.MatchString(s) {
+		return parsedAmount{}, fmt.Errorf("invalid amount: %q", raw)
+	}
+
+	value, err := strconv.ParseFloat(normalizeDigits(s, decimalMark(locale)), 64)
+	if err != nil {
+		return parsedAmount{}, fmt.Errorf("invalid amount: %q", raw)
+	}
+	if negative {
+		value = -value
+	}
+	amt.Value = value
+	return amt, nil
+}
+
+// stripCurrency removes a leading or trailing currency symbol or code.
+func stripCurrency(s, found string) (string, string) {
+	lower := strings.ToLower(s)
+	for _, c := range currencySymbols {
+		if strings.HasPrefix(lower, c.symbol) {
+			return strings.TrimSpace(s[len(c.symbol):]), c.code
+		}
+		if strings.HasSuffix(lower, c.symbol) {
+			return strings.TrimSpace(s[:len(s)-len(c.symbol)]), c.code
+		}
+	}
+	return s, found
+}
+
+// moneySymbols are the symbols insights write amounts with. Other currencies
+// are written with their ISO code.
+var moneySymbols = map[string]string{"INR": "₹", "USD": "$", "EUR": "€", "GBP": "£", "JPY": "¥"}
+
+// formatMoney writes a whole amount in a currency: "₹1200", "$45" or "AED 300".
+// Negative amounts keep their sign in front: "-₹1200".
+func formatMoney(amount float64, currency string) string {
+	sign := ""
+	if math.Round(amount) < 0 {
+		sign, amount = "-", -amount
+	}
+	if symbol, ok := moneySymbols[currency]; ok {
+		return fmt.Sprintf("%s%s%.0f", sign, symbol, amount)
+	}
+	return fmt.Sprintf("%s%s %.0f", sign, currency, amount)
+}
+
+// normalizeDigits drops grouping characters and turns the decimal mark into a
+// dot. With mark 0 the last separator is the decimal mark unless it is followed
+// by exactly three digits and there is no other kind of separator ("1,234").
+func normalizeDigits(s string, mark rune) string {
+	s = strings.NewReplacer(" ", "", "'", "", "\u00a0", "", "\u202f", "").Replace(s)
+
+	if mark == 0 {
+		lastComma, lastDot := strings.LastIndex(s, ","), strings.LastIndex(s, ".")
+		switch {
+		case lastComma >= 0 && lastDot >= 0:
+			mark = '.'
+			if lastComma > lastDot {
+				mark = ','
+			}
+		case lastComma >= 0:
+			mark = ','
+			if len(s)-lastComma-1 == 3 || strings.Count(s, ",") > 1 {
+				mark = '.' // Grouping only: "1,234" or "1,23,456"
+			}
+		default:
+			mark = '.'
+			if strings.Count(s, ".") > 1 {
+				mark = ',' // "1.234.567" groups with dots
+			}
+		}
+	}
+
+	group := ","
+	if mark == ',' {
+		group = "."
+	}
+	s = strings.ReplaceAll(s, group, "")
+	return strings.Replace(s, string(mark), ".", 1)
+}
+
+// parseAmount reads an amount in any of the notations parseMoney accepts,
+// guessing the decimal mark. Callers work out the direction from the format's
+// own sign or indicator and then apply the markers with kind.
+func parseAmount(s string) (parsedAmount, error) {
+	return parseMoney(s, "")
+}
+
+// kind applies an explicit Dr/Cr marker over the direction a format gave:
+// "450.00 Cr" is money in whatever the sign says.
+func (a parsedAmount) kind(given string) string {
+	switch {
+	case a.Credit:
+		return models.KindCredit
+	case a.Debit:
+		return models.KindDebit
+	}
+	return given
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+// camtEntry is the part of an ISO 20022 camt.053 <Ntry> we use. Party names sit
+// directly under Cdtr/Dbtr in older versions and under Cdtr/Pty from .08 on.
+type camtEntry struct {
+	Amount      camtAmount `xml:"Amt"`
+	CdtDbtInd   string     `xml:"CdtDbtInd"`
+	RvslInd     bool       `xml:"RvslInd"`
+	BookingDate camtDate   `xml:"BookgDt"`
+	ValueDate   camtDate   `xml:"ValDt"`
+	AcctSvcrRef string     `xml:"AcctSvcrRef"`
+	Info        string     `xml:"AddtlNtryInf"`
+	Details     []struct {
+		AcctSvcrRef string   `xml:"Refs>AcctSvcrRef"`
+		TxID        string   `xml:"Refs>TxId"`
+		Creditor    []string `xml:"RltdPties>Cdtr>Nm"`
+		CreditorPty []string `xml:"RltdPties>Cdtr>Pty>Nm"`
+		Debtor      []string `xml:"RltdPties>Dbtr>Nm"`
+		DebtorPty   []string `xml:"RltdPties>Dbtr>Pty>Nm"`
+		Unstructed  []string `xml:"RmtInf>Ustrd"`
+		CreditorRef []string `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+		Info        string   `xml:"AddtlTxInf"`
+	} `xml:"NtryDtls>TxDtls"`
+}
+
+// camtAmount is an <Amt> with its Ccy attribute, e.g. <Amt Ccy="EUR">12.50</Amt>.
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+// camtAccount is the <Acct> a statement reports on.
+type camtAccount struct {
+	IBAN     string `xml:"Id>IBAN"`
+	Other    string `xml:"Id>Othr>Id"`
+	Currency string `xml:"Ccy"`
+	Servicer string `xml:"Svcr>FinInstnId>Nm"`
+	BIC      string `xml:"Svcr>FinInstnId>BICFI"`
+	BICOld   string `xml:"Svcr>FinInstnId>BIC"`
+}
+
+func (a camtAccount) label() string {
+	number := firstNonEmpty(a.IBAN, a.Other)
+	if number == "" {
+		return ""
+	}
+	return accountLabel(firstNonEmpty(a.Servicer, a.BIC, a.BICOld), number)
+}
+
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+func (d camtDate) day() string {
+	if d.Date != "" {
+		return d.Date
+	}
+	if len(d.DateTime) >= 10 {
+		return d.DateTime[:10]
+	}
+	return ""
+}
+
+// when reads the date, keeping the time of day of a DtTm.
+func (d camtDate) when() (time.Time, error) {
+	if d.Date == "" {
+		if day, clock, ok := splitClock(d.DateTime); ok {
+			t, err := time.Parse(models.DateLayout, day)
+			return t.Add(clock), err
+		}
+	}
+	return time.Parse(models.DateLayout, d.day())
+}
+
+// StreamCamt053 reads an ISO 20022 camt.053 bank-to-customer statement and
+// calls fn for every entry, debit or credit.
+func (p *ParserService) StreamCamt053(ctx context.Context, r io.Reader, fn ExpenseFunc) (*models.ParseReport, error) {
+	rep := newReporter(FormatCamt053)
+	var account, currency string
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil // Statements are UTF-8 or ASCII in practice
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return rep.finish(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if ok && start.Name.Local == "Acct" {
+			var acct camtAccount
+			if err := decoder.DecodeElement(&acct, &start); err != nil {
+				return nil, err
+			}
+			account = acct.label()
+			currency = acct.Currency
+			continue
+		}
+		if !ok || start.Name.Local != "Ntry" {
+			continue
+		}
+
+		line, _ := decoder.InputPos()
+		var entry camtEntry
+		if err := decoder.DecodeElement(&entry, &start); err != nil {
+			return nil, err
+		}
+		expense, err := camtExpense(entry)
+		if err != nil {
+			rep.skip(line, err)
+			continue
+		}
+		rep.imported("2006-01-02")
+		expense.Account = account
+		expense.Currency = strings.ToUpper(firstNonEmpty(expense.Currency, currency))
+		if err := fn(expense); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func camtExpense(entry camtEntry) (models.Expense, error) {
+	amt, err := parseAmount(entry.Amount.Value)
+	if err != nil {
+		return models.Expense{}, skipRow(SkipInvalidAmount, "unrecognised amount %q", entry.Amount.Value)
+	}
+	if amt.Value == 0 {
+		return models.Expense{}, skipRowWarning(SkipZeroAmount, "%s entry of zero", entry.CdtDbtInd)
+	}
+	// A reversed credit takes money out, a reversed debit puts it back, and so
+	// does a negative amount.
+	kind := models.KindCredit
+	if strings.EqualFold(entry.CdtDbtInd, "DBIT") != entry.RvslInd != (amt.Value < 0) {
+		kind = models.KindDebit
+	}
+	amount, kind := math.Abs(amt.Value), amt.kind(kind)
+
+	booking := entry.BookingDate
+	if booking.day() == "" {
+		booking = entry.ValueDate
+	}
+	date, err := booking.when()
+	if err != nil {
+		return models.Expense{}, skipRow(SkipInvalidDate, "unrecognised booking date %q", firstNonEmpty(booking.Date, booking.DateTime))
+	}
+
+	var counterparty, remittance []string
+	reference := entry.AcctSvcrRef
+	for _, tx := range entry.Details {
+		// The other side is the creditor on a debit and the debtor on a credit.
+		payee, payer := slices.Concat(tx.Creditor, tx.CreditorPty), slices.Concat(tx.Debtor, tx.DebtorPty)
+		if kind == models.KindCredit {
+			payee, payer = payer, payee
+		}
+		counterparty = append(counterparty, payee...)
+		if len(payee) == 0 {
+			counterparty = append(counterparty, payer...)
+		}
+		remittance = append(remittance, tx.Unstructed...)
+		remittance = append(remittance, tx.CreditorRef...)
+		if tx.Info != "" {
+			remittance = append(remittance, tx.Info)
+		}
+		if reference == "" {
+			reference = firstNonEmpty(tx.AcctSvcrRef, tx.TxID)
+		}
+	}
+	if len(remittance) == 0 && entry.Info != "" {
+		remittance = append(remittance, entry.Info)
+	}
+
+	return models.Expense{
+		Date:          date,
+		Description:   foldDescription(strings.Join(counterparty, ", "), strings.Join(remittance, " "), booking.day(), entry.ValueDate.day()),
+		Amount:        amount,
+		Kind:          kind,
+		TransactionID: reference,
+		Currency:      entry.Amount.Currency,
+	}, nil
+}
@@ -1,65 +1,222 @@
 package services
 
 import (
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/siddhartharajbongshi/spendsense-backend/models"
 )
 
+// CategorizerService assigns categories to transactions from an ordered list
+// of rules, so the same description always lands in the same category.
 type CategorizerService struct {
-	keywordMap map[string][]string
+	mu         sync.RWMutex
+	categories []string // Spending categories; Misc and Income are implied
+	rules      []categoryRule
+}
+
+// qifCategories maps our categories to the names Quicken and similar desktop
+// tools use, for QIF export.
+var qifCategories = map[string]string{
+	"Food":          "Food:Dining Out",
+	"Transport":     "Auto:Transport",
+	"Subscriptions": "Entertainment:Subscriptions",
+	"Shopping":      "Shopping",
+	"Rent":          "Housing:Rent",
+	"Utilities":     "Utilities",
+	"Misc":          "Miscellaneous",
+	"Income":        "Income",
+}
+
+// categoryAliases maps common external category names (QIF L lines, other
+// apps' exports) onto our categories. Keys are lowercase.
+var categoryAliases = map[string]string{
+	"dining": "Food", "dining out": "Food", "restaurants": "Food", "groceries": "Food",
+	"auto": "Transport", "fuel": "Transport", "gas": "Transport", "travel": "Transport",
+	"entertainment": "Subscriptions", "subscriptions": "Subscriptions", "cable": "Subscriptions",
+	"clothing": "Shopping", "household": "Shopping", "gifts": "Shopping",
+	"housing": "Rent", "mortgage": "Rent",
+	"electric": "Utilities", "telephone": "Utilities", "water": "Utilities", "internet": "Utilities",
+	"miscellaneous": "Misc",
+	"income":        "Income", "salary": "Income", "wages": "Income", "bonus": "Income",
+	"interest": "Income", "int inc": "Income", "div income": "Income", "dividends": "Income",
+}
+
+// defaultCategories are the spending categories the built-in rules use.
+var defaultCategories = []string{"Food", "Transport", "Subscriptions", "Shopping", "Rent", "Utilities"}
+
+// defaultCategoryRules are the built-in rules. IDs are stable so a result can
+// be traced back to the rule that produced it.
+var defaultCategoryRules = []models.CategoryRule{
+	{ID: "food-delivery", Category: "Food", Priority: PriorityMerchant, Keywords: []string{
+		"zomato", "swiggy", "dunzo", "uber eats", "eatsure",
+	}},
+	{ID: "food-chains", Category: "Food", Priority: PriorityMerchant, Keywords: []string{
+		"burger king", "domino", "dominos", "pizza hut", "mcd", "mcdonald", "mcdonalds",
+		"kfc", "starbucks", "third wave coffee", "cafe coffee day", "chai point", "haldiram",
+	}},
+	{ID: "food-grocers", Category: "Food", Priority: PriorityMerchant, Keywords: []string{
+		"bigbasket", "blinkit", "zepto", "instamart",
+	}},
+	{ID: "food-groceries", Category: "Food", Priority: PrioritySpecific, Keywords: []string{
+		"grocery", "groceries", "vegetables", "fruits", "kirana",
+	}},
+	{ID: "food-generic", Category: "Food", Priority: PriorityGeneric, Keywords: []string{
+		"coffee", "restaurant", "cafe", "canteen", "food", "meal", "snacks", "eat", "dine", "bakery",
+	}},
+	{ID: "transport-merchants", Category: "Transport", Priority: PriorityMerchant, Keywords: []string{
+		"uber", "ola", "rapido", "irctc", "redbus", "makemytrip", "indigo",
+	}},
+	{ID: "transport-fuel", Category: "Transport", Priority: PrioritySpecific, Keywords: []string{
+		"petrol", "diesel", "fuel", "parking", "fastag", "toll",
+	}},
+	{ID: "transport-generic", Category: "Transport", Priority: PriorityGeneric, Keywords: []string{
+		"auto", "autorickshaw", "taxi", "cab", "metro", "bus", "train", "flight", "travel", "ride",
+	}},
+	{ID: "subscriptions-streaming", Category: "Subscriptions", Priority: PriorityMerchant, Keywords: []string{
+		"netflix", "amazon prime", "prime video", "spotify", "youtube", "youtube premium",
+		"hulu", "disney", "hotstar", "jiocinema", "sonyliv",
+	}},
+	{ID: "subscriptions-generic", Category: "Subscriptions", Priority: PriorityGeneric, Keywords: []string{
+		"subscription", "premium", "membership", "plan",
+	}},
+	{ID: "shopping-merchants", Category: "Shopping", Priority: PriorityMerchant, Keywords: []string{
+		"amazon", "flipkart", "myntra", "ajio", "nykaa", "meesho", "dmart", "croma", "decathlon", "ikea",
+	}},
+	{ID: "shopping-generic", Category: "Shopping", Priority: PriorityGeneric, Keywords: []string{
+		"supermarket", "mall", "clothing", "dress", "shoe", "shoes", "shopping", "purchase", "retail", "store",
+	}},
+	{ID: "rent", Category: "Rent", Priority: PrioritySpecific, Keywords: []string{
+		"rent", "house rent", "landlord", "lease", "housing",
+	}},
+	// A deposit is a rent deposit only when it is large; small ones are
+	// usually refundable bookings.
+	{ID: "rent-deposit", Category: "Rent", Priority: PrioritySpecific, Keywords: []string{
+		"deposit", "security deposit",
+	}, MinAmount: 5000},
+	{ID: "utilities-recharge", Category: "Utilities", Priority: PrioritySpecific,
+		Pattern: `\b(?:mobile|prepaid|postpaid|dth|jio|airtel|vi|bsnl)\s+(?:recharge|bill)\b`},
+	{ID: "utilities", Category: "Utilities", Priority: PrioritySpecific, Keywords: []string{
+		"electricity", "water", "internet", "wifi", "broadband", "phone", "gas cylinder",
+	}},
+	{ID: "utilities-generic", Category: "Utilities", Priority: PriorityGeneric, Keywords: []string{
+		"bill", "recharge",
+	}},
 }
 
 func NewCategorizerService() *CategorizerService {
-	return &CategorizerService{
-		keywordMap: map[string][]string{
-			"Food": {
-				"zomato", "swiggy", "dunzo", "burger king", "domino",
-				"pizza hut", "mcd", "starbucks", "coffee", "restaurant",
-				"food", "meal", "eat", "dine",
-			},
-			"Transport": {
-				"uber", "ola", "auto", "autorickshaw", "taxi", "cab",
-				"petrol", "fuel", "parking", "metro", "bus", "train",
-				"travel", "ride",
-			},
-			"Subscriptions": {
-				"netflix", "amazon prime", "spotify", "youtube", "hulu",
-				"disney", "hotstar", "subscription", "premium", "plan",
-				"membership",
-			},
-			"Shopping": {
-				"amazon", "flipkart", "dmart", "supermarket", "mall",
-				"clothing", "dress", "shoe", "shopping", "purchase",
-				"retail", "store",
-			},
-			"Rent": {
-				"rent", "housing", "landlord", "deposit", "lease",
-			},
-			"Utilities": {
-				"electricity", "water", "internet", "wifi", "broadband",
-				"mobile recharge", "phone", "bill",
-			},
-		},
+	c := &CategorizerService{categories: slices.Clone(defaultCategories)}
+	for _, r := range defaultCategoryRules {
+		rule, err := compileRule(r)
+		if err != nil {
+			panic(err)
+		}
+		c.rules = append(c.rules, rule)
 	}
+	return c
 }
 
-func (c *CategorizerService) Categorize(description string) string {
-	descLower := strings.ToLower(description)
+// Categorize picks the category for a description and amount, and the ID of
+// the rule that chose it. Descriptions no rule matches are Misc.
+func (c *CategorizerService) Categorize(description string, amount float64) (category, ruleID string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if rule, ok := bestRule(c.rules, description, amount); ok {
+		return rule.Category, rule.ID
+	}
+	return "Misc", ""
+}
 
-	for category, keywords := range c.keywordMap {
-		for _, keyword := range keywords {
-			if strings.Contains(descLower, keyword) {
-				return category
-			}
+// MatchCategory resolves an external category name such as "Food:Dining Out" or
+// "Auto:Fuel" to one of ours. The most specific part of a colon path wins.
+func (c *CategorizerService) MatchCategory(name string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	parts := strings.Split(name, ":")
+	for i := len(parts) - 1; i >= 0; i-- {
+		part := strings.ToLower(strings.TrimSpace(parts[i]))
+		if part == "" {
+			continue
+		}
+		if category, ok := c.category(part); ok {
+			return category, true
 		}
+		if category, ok := categoryAliases[part]; ok {
+			return category, true
+		}
+	}
+	return "", false
+}
+
+// QIFCategory is the export name for one of our categories.
+func (c *CategorizerService) QIFCategory(category string) string {
+	if name, ok := qifCategories[category]; ok {
+		return name
 	}
-	return "Misc"
+	return category
 }
 
-func (c *CategorizerService) CategorizeExpenses(expenses []models.Expense) []models.Expense {
+// CategorizeExpenses fills in Category for every expense. A debit from a
+// merchant with an override goes to the category the user chose for it.
+// Otherwise categories that came with the import are mapped onto ours, falling
+// back to the rules for debits and to Income for credits. Transactions the
+// user categorized by hand are left alone.
+func (c *CategorizerService) CategorizeExpenses(expenses []models.Expense, overrides MerchantOverrides) []models.Expense {
 	for i := range expenses {
-		expenses[i].Category = c.Categorize(expenses[i].Description)
+		if expenses[i].CategoryRule == RuleManual {
+			continue
+		}
+		if override, ok := overrides.Lookup(expenses[i]); ok && !expenses[i].IsCredit() {
+			if category, ok := c.Category(override.Category); ok {
+				expenses[i].Category, expenses[i].CategoryRule = category, RuleMerchant
+				continue
+			}
+		}
+		if category, ok := c.MatchCategory(expenses[i].Category); ok {
+			expenses[i].Category = category
+			continue
+		}
+		if expenses[i].IsCredit() {
+			expenses[i].Category = "Income"
+			continue
+		}
+		// The clean merchant name is matched along with the narration, so
+		// "BUNDL TECHNOLOGIES" is Swiggy and "Amazon Prime" in the narration
+		// still beats the merchant "Amazon". The separator keeps keywords from
+		// spanning the two.
+		text := expenses[i].Description
+		if expenses[i].Merchant != "" {
+			text = expenses[i].Merchant + " | " + text
+		}
+		expenses[i].Category, expenses[i].CategoryRule = c.Categorize(text, expenses[i].Amount)
 	}
 	return expenses
 }
+
+// Recategorize applies the current rules and overrides to stored transactions
+// after they changed. Categories a rule or override chose, and Misc, are
+// worked out again; ones that came with the import or were picked by hand are
+// kept while they still exist. Refunds go back to Income until
+// RefundService.Match links them again.
+func (c *CategorizerService) Recategorize(expenses []models.Expense, overrides MerchantOverrides) {
+	for i := range expenses {
+		e := &expenses[i]
+		if e.CategoryRule == RuleManual {
+			if _, ok := c.Category(e.Category); ok {
+				continue
+			}
+		}
+		if e.CategoryRule != "" || e.Category == "Misc" || e.IsRefund() {
+			e.Category, e.CategoryRule = "", ""
+		}
+	}
+	c.CategorizeExpenses(expenses, overrides)
+}
+
+// Category finds a spending category by name, ignoring case.
+func (c *CategorizerService) Category(name string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.category(name)
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateLayouts are the layouts bank files use, day-first before month-first.
+// Go's "1" and "2" accept one or two digits and month names match in any case,
+// so "03/04/2026", "3/4/2026", "02-JAN-26" and "2 Jan 2026" are all covered.
+var dateLayouts = []string{
+	"2006-1-2", "2006/1/2", "2006.1.2", "2006-Jan-2",
+	"2/1/2006", "1/2/2006", "2-1-2006", "1-2-2006", "2.1.2006", "1.2.2006",
+	"2/1/06", "1/2/06", "2-1-06", "1-2-06", "2.1.06", "1.2.06",
+	"2 Jan 2006", "2-Jan-2006", "2/Jan/2006", "2Jan2006", "2 January 2006",
+	"2 Jan 06", "2-Jan-06", "2/Jan/06", "2Jan06",
+	"Jan 2, 2006", "Jan 2 2006", "January 2, 2006", "January 2 2006",
+}
+
+// clockPattern matches a time of day at the end of a value, alone or after a
+// date: "23:41", "23:41:05", "11:41 PM", "2026-01-05T23:41:05+05:30". A zone
+// is accepted but not applied; times are kept as the source wrote them.
+var clockPattern = regexp.MustCompile(`(?i)(?:^|\s|T)(\d{1,2}):(\d{2})(?::(\d{2})(?:\.\d+)?)?(?:\s*([ap])\.?m\.?)?\s*(?:z|[+-]\d{2}:?\d{2})?$`)
+
+// splitClock cuts a time of day off the end of s and returns the rest and the
+// time as an offset from midnight. ok is false when s ends in no valid time.
+func splitClock(s string) (rest string, clock time.Duration, ok bool) {
+	s = strings.TrimSpace(s)
+	m := clockPattern.FindStringSubmatchIndex(s)
+	if m == nil {
+		return s, 0, false
+	}
+	group := func(i int) string {
+		if m[2*i] < 0 {
+			return ""
+		}
+		return s[m[2*i]:m[2*i+1]]
+	}
+	hour, _ := strconv.Atoi(group(1))
+	minute, _ := strconv.Atoi(group(2))
+	second, _ := strconv.Atoi(group(3))
+	switch strings.ToLower(group(4)) {
+	case "a", "p":
+		if hour < 1 || hour > 12 {
+			return s, 0, false
+		}
+		hour %= 12
+		if strings.EqualFold(group(4), "p") {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 || second > 59 {
+		return s, 0, false
+	}
+	clock = time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute + time.Duration(second)*time.Second
+	return strings.TrimSpace(s[:m[0]]), clock, true
+}
+
+// calendarDay drops the time of day from t.
+func calendarDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// dateInferrer picks one date order for a whole file. It starts from every
+// plausible layout and drops those that fail to parse a date seen in the file,
+// so a single "13/04" settles day-first vs month-first for every row.
+type dateInferrer struct {
+	all        []string // Every layout considered, in preference order
+	candidates []string
+	values     []string // Dates seen while still undecided, for the ambiguity check
+	override   bool
+}
+
+// newDateInferrer orders candidates by preference: an explicit layout wins
+// outright, then the profile's own layouts, then month-first for US locales and
+// day-first for everyone else.
+func newDateInferrer(preferred []string, override, locale string) *dateInferrer {
+	if override != "" {
+		layouts := []string{dateLayoutFromPattern(override)}
+		return &dateInferrer{all: layouts, candidates: layouts, override: true}
+	}
+
+	monthFirst := strings.EqualFold(locale, "en-US") || strings.EqualFold(locale, "en_US")
+	var first, second []string
+	for _, layout := range dateLayouts {
+		if (dateOrder(layout) == "MDY") == monthFirst {
+			first = append(first, layout)
+		} else {
+			second = append(second, layout)
+		}
+	}
+	candidates := append(append(append([]string(nil), preferred...), first...), second...)
+	return &dateInferrer{all: candidates, candidates: candidates}
+}
+
+// observe narrows the candidates to the layouts that can parse s. Values that
+// no candidate understands (footers, blanks) leave the set alone. A time of day
+// after the date is ignored.
+func (d *dateInferrer) observe(s string) {
+	if d.decided() {
+		return
+	}
+	s, _, _ = splitClock(s)
+	var matching []string
+	for _, layout := range d.candidates {
+		if _, err := time.Parse(layout, s); err == nil {
+			matching = append(matching, layout)
+		}
+	}
+	if len(matching) > 0 {
+		d.candidates = matching
+		d.values = append(d.values, s)
+	}
+}
+
+// decided reports whether every remaining layout orders day, month and year the
+// same way, so later rows cannot change the outcome.
+func (d *dateInferrer) decided() bool {
+	if d.override || len(d.candidates) <= 1 {
+		return true
+	}
+	order := dateOrder(d.candidates[0])
+	for _, layout := range d.candidates[1:] {
+		if dateOrder(layout) != order {
+			return false
+		}
+	}
+	return true
+}
+
+// layout is the preferred remaining candidate.
+func (d *dateInferrer) layout() string {
+	return d.candidates[0]
+}
+
+// parse reads s with the chosen layout, falling back to other layouts with the
+// same field order: statements mix "1/15'26" with "1/16/2026", or "02 Jan 2026"
+// with "02-JAN-26", but never day-first with month-first. It returns the layout
+// that matched. A time of day after the date is added to the result, unless an
+// explicit layout reads the time itself.
+func (d *dateInferrer) parse(s string) (time.Time, string, error) {
+	s = strings.TrimSpace(s)
+	if !d.override || !strings.Contains(d.layout(), "15") {
+		if date, clock, ok := splitClock(s); ok {
+			t, layout, err := d.parse(date)
+			return t.Add(clock), layout, err
+		}
+	}
+	chosen := d.layout()
+	t, err := time.Parse(chosen, s)
+	if err == nil || d.override {
+		return t, chosen, err
+	}
+	order := dateOrder(chosen)
+	for _, layout := range d.all {
+		if dateOrder(layout) != order {
+			continue
+		}
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, layout, nil
+		}
+	}
+	return time.Time{}, chosen, err
+}
+
+// alternatives lists the other remaining layouts that would have read at least
+// one of the file's dates differently. A non-empty result means the file is
+// genuinely ambiguous, e.g. every day is 12 or below.
+func (d *dateInferrer) alternatives() []string {
+	if d.decided() {
+		return nil
+	}
+	chosen := d.layout()
+	var alts []string
+	for _, layout := range d.candidates[1:] {
+		if dateOrder(layout) == dateOrder(chosen) {
+			continue
+		}
+		for _, v := range d.values {
+			a, _ := time.Parse(chosen, v)
+			b, _ := time.Parse(layout, v)
+			if !a.Equal(b) {
+				alts = append(alts, layout)
+				break
+			}
+		}
+	}
+	return alts
+}
+
+// dateOrder describes the field order of a layout as e.g. "DMY" or "MDY".
+func dateOrder(layout string) string {
+	var order []byte
+	for i := 0; i < len(layout); {
+		switch {
+		case strings.HasPrefix(layout[i:], "2006"):
+			order = append(order, 'Y')
+			i += 4
+		case strings.HasPrefix(layout[i:], "January"):
+			order = append(order, 'M')
+			i += 7
+		case strings.HasPrefix(layout[i:], "Jan"):
+			order = append(order, 'M')
+			i += 3
+		case strings.HasPrefix(layout[i:], "01"):
+			order = append(order, 'M')
+			i += 2
+		case strings.HasPrefix(layout[i:], "02"):
+			order = append(order, 'D')
+			i += 2
+		case strings.HasPrefix(layout[i:], "06"):
+			order = append(order, 'Y')
+			i += 2
+		case strings.HasPrefix(layout[i:], "15"):
+			i += 2 // Hour, not month
+		case layout[i] == '1':
+			order = append(order, 'M')
+			i++
+		case layout[i] == '2':
+			order = append(order, 'D')
+			i++
+		default:
+			i++
+		}
+	}
+	return string(order)
+}
+
+// dateLayoutFromPattern accepts either a Go layout or the DD/MM/YYYY notation
+// users know. Patterns without a time part are read case-insensitively, so
+// "dd/mm/yyyy" means day/month/year rather than minutes.
+func dateLayoutFromPattern(pattern string) string {
+	if strings.ContainsAny(pattern, "0123456789") {
+		return pattern // Already a Go layout
+	}
+	if !strings.ContainsAny(pattern, "hH:") {
+		pattern = strings.ToUpper(pattern)
+	}
+	return strings.NewReplacer(
+		"YYYY", "2006", "YY", "06", "MMMM", "January", "MMM", "Jan", "MM", "01", "M", "1",
+		"DD", "02", "D", "2", "hh", "15", "HH", "15", "mm", "04", "ss", "05",
+	).Replace(pattern)
+}
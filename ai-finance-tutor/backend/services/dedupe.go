@@ -0,0 +1,112 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+type DedupeService struct{}
+
+func NewDedupeService() *DedupeService {
+	return &DedupeService{}
+}
+
+// Fingerprint identifies a transaction across uploads. A bank reference, when
+// the format carries one, is what makes a transaction unique; otherwise the
+// date, the description with punctuation and case ignored, the amount and the
+// direction are. Only the day counts, so a file with times matches one without.
+// The amount is the one the statement gave, so fingerprints do
+// not change with the base currency.
+func (s *DedupeService) Fingerprint(e models.Expense) string {
+	return fingerprint(e)
+}
+
+func fingerprint(e models.Expense) string {
+	kind := e.Kind
+	if kind == "" {
+		kind = models.KindDebit
+	}
+	key := fmt.Sprintf("%s|%.2f|%s|", e.Day(), originalAmount(e), kind)
+	if ref := strings.TrimSpace(e.TransactionID); ref != "" {
+		key += "ref:" + strings.ToLower(ref)
+	} else {
+		key += "desc:" + normalizeDescription(e.Description)
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Merge appends the incoming transactions that existing does not already hold
+// and returns the result sorted by date. Fingerprints are counted rather than
+// just checked, so two identical coffees on the same day survive a re-upload of
+// the same statement as two transactions, not one or four.
+func (s *DedupeService) Merge(existing, incoming []models.Expense) ([]models.Expense, models.MergeSummary) {
+	seen := make(map[string]int, len(existing))
+	merged := make([]models.Expense, 0, len(existing)+len(incoming))
+	for _, e := range existing {
+		if e.Fingerprint == "" {
+			e.Fingerprint = s.Fingerprint(e)
+		}
+		seen[e.Fingerprint]++
+		merged = append(merged, e)
+	}
+
+	var summary models.MergeSummary
+	for _, e := range incoming {
+		e.Fingerprint, e.ID = s.Fingerprint(e), ""
+		if seen[e.Fingerprint] > 0 {
+			seen[e.Fingerprint]--
+			summary.DuplicatesSkipped++
+			continue
+		}
+		merged = append(merged, e)
+		summary.Added++
+	}
+
+	sortExpenses(merged)
+	assignIDs(merged)
+	summary.Total = len(merged)
+	return merged, summary
+}
+
+// assignIDs gives every transaction a fingerprint and an ID no other one in
+// expenses has: the fingerprint and its occurrence, so two identical teas on
+// one day are "<fingerprint>-1" and "<fingerprint>-2". IDs already set are
+// kept unless repeated.
+func assignIDs(expenses []models.Expense) {
+	used := make(map[string]bool, len(expenses))
+	for i := range expenses {
+		e := &expenses[i]
+		if used[e.ID] {
+			e.ID = ""
+		}
+		if e.ID != "" {
+			used[e.ID] = true
+		}
+	}
+	for i := range expenses {
+		e := &expenses[i]
+		if e.Fingerprint == "" {
+			e.Fingerprint = fingerprint(*e)
+		}
+		for n := 1; e.ID == ""; n++ {
+			if id := fmt.Sprintf("%s-%d", e.Fingerprint, n); !used[id] {
+				e.ID, used[id] = id, true
+			}
+		}
+	}
+}
+
+// normalizeDescription lowercases a description and reduces every run of
+// punctuation and spacing to one space, so "UPI-ZOMATO / Order" and
+// "upi zomato order" compare equal.
+func normalizeDescription(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Text encodings recognised by newTextReader and reported in ParseReport.Encoding.
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+	EncodingCP1252  = "windows-1252"
+)
+
+// cp1252High maps bytes 0x80-0x9F of Windows-1252; the rest of the code page
+// matches Latin-1. Unassigned bytes keep their C1 code point.
+var cp1252High = [32]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+}
+
+func cp1252Rune(b byte) rune {
+	if b >= 0x80 && b <= 0x9F {
+		return cp1252High[b-0x80]
+	}
+	return rune(b)
+}
+
+// textReader decodes an upload to UTF-8 for the text importers. Invalid UTF-8
+// bytes are read as Windows-1252, the code page most Windows banking software
+// exports in, so "Caf\xe9" becomes "Café" rather than garbage.
+type textReader struct {
+	r        *bufio.Reader
+	encoding string
+	strict   bool // UTF-8 was asked for: invalid bytes become U+FFFD
+	fallback bool // Saw bytes that were not valid UTF-8
+	pending  []byte
+}
+
+// newTextReader picks the encoding of r from its byte order mark, from the NUL
+// bytes ASCII text leaves in UTF-16, or from override when set. A BOM is
+// consumed so it never ends up in the first header cell.
+func newTextReader(r *bufio.Reader, override string) (*textReader, error) {
+	t := &textReader{r: r, encoding: EncodingUTF8}
+	head, _ := r.Peek(sniffLen)
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		r.Discard(3)
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		r.Discard(2)
+		t.encoding = EncodingUTF16LE
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		r.Discard(2)
+		t.encoding = EncodingUTF16BE
+	default:
+		t.encoding = sniffUTF16(head)
+	}
+
+	if override != "" {
+		switch strings.ToLower(strings.ReplaceAll(override, "_", "-")) {
+		case "utf-8", "utf8":
+			t.encoding = EncodingUTF8
+			t.strict = true
+		case "utf-16le", "utf16le":
+			t.encoding = EncodingUTF16LE
+		case "utf-16be", "utf16be":
+			t.encoding = EncodingUTF16BE
+		case "utf-16", "utf16":
+			if t.encoding != EncodingUTF16BE {
+				t.encoding = EncodingUTF16LE
+			}
+		case "windows-1252", "cp1252", "latin1", "latin-1", "iso-8859-1":
+			t.encoding = EncodingCP1252
+		default:
+			return nil, fmt.Errorf("unsupported encoding: %s", override)
+		}
+	}
+	return t, nil
+}
+
+// sniffUTF16 spots BOM-less UTF-16 by where the zero bytes fall.
+func sniffUTF16(head []byte) string {
+	var even, odd int
+	for i := 0; i+1 < len(head); i += 2 {
+		if head[i] == 0 {
+			even++
+		}
+		if head[i+1] == 0 {
+			odd++
+		}
+	}
+	pairs := len(head) / 2
+	switch {
+	case pairs < 2:
+		return EncodingUTF8
+	case odd > pairs/2 && even == 0:
+		return EncodingUTF16LE
+	case even > pairs/2 && odd == 0:
+		return EncodingUTF16BE
+	default:
+		return EncodingUTF8
+	}
+}
+
+// Encoding reports what the input turned out to be. A UTF-8 file with stray
+// Windows-1252 bytes reports the latter.
+func (t *textReader) Encoding() string {
+	if t.encoding == EncodingUTF8 && t.fallback {
+		return EncodingCP1252
+	}
+	return t.encoding
+}
+
+func (t *textReader) Read(p []byte) (int, error) {
+	if t.encoding == EncodingUTF8 && len(t.pending) == 0 {
+		// Pass buffered bytes through untouched while they are valid UTF-8.
+		buf, _ := t.r.Peek(min(len(p), t.r.Buffered()))
+		if n := validUTF8Prefix(buf); n > 0 {
+			copy(p, buf[:n])
+			t.r.Discard(n)
+			return n, nil
+		}
+	}
+
+	for len(t.pending) < len(p) {
+		r, err := t.next()
+		if err != nil {
+			if len(t.pending) == 0 {
+				return 0, err
+			}
+			break
+		}
+		t.pending = utf8.AppendRune(t.pending, r)
+	}
+	n := copy(p, t.pending)
+	t.pending = append(t.pending[:0], t.pending[n:]...)
+	return n, nil
+}
+
+func (t *textReader) next() (rune, error) {
+	switch t.encoding {
+	case EncodingUTF16LE, EncodingUTF16BE:
+		u, err := t.unit()
+		if err != nil {
+			return 0, err
+		}
+		if !utf16.IsSurrogate(u) {
+			return u, nil
+		}
+		u2, err := t.unit()
+		if err != nil {
+			return utf8.RuneError, nil
+		}
+		return utf16.DecodeRune(u, u2), nil
+	case EncodingCP1252:
+		b, err := t.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		return cp1252Rune(b), nil
+	default:
+		r, size, err := t.r.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		if r == utf8.RuneError && size == 1 && !t.strict {
+			t.r.UnreadRune()
+			b, _ := t.r.ReadByte()
+			t.fallback = true
+			return cp1252Rune(b), nil
+		}
+		return r, nil
+	}
+}
+
+// validUTF8Prefix is the length of the longest prefix of b made of complete,
+// valid UTF-8 sequences.
+func validUTF8Prefix(b []byte) int {
+	i := 0
+	for i < len(b) {
+		if b[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(b[i:])
+		if r == utf8.RuneError && size == 1 {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// unit reads one UTF-16 code unit. A dangling odd byte ends the input.
+func (t *textReader) unit() (rune, error) {
+	b0, err := t.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	b1, err := t.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if t.encoding == EncodingUTF16BE {
+		return rune(b0)<<8 | rune(b1), nil
+	}
+	return rune(b1)<<8 | rune(b0), nil
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+// Statement formats understood by ParserService.Stream.
+const (
+	FormatCSV     = "csv"
+	FormatOFX     = "ofx"
+	FormatQIF     = "qif"
+	FormatCamt053 = "camt053"
+	FormatMT940   = "mt940"
+	FormatXLSX    = "xlsx"
+	FormatSMS     = "sms"
+	FormatJSON    = "json"   // An array of transactions
+	FormatNDJSON  = "ndjson" // One transaction object per line
+)
+
+// sniffLen is how much of an upload is inspected to guess its format.
+const sniffLen = 1024
+
+// MT940 tags open a line. A statement needs its reference (:20:), the account
+// (:25:) or opening balance (:60F:), and at least one entry (:61:); times such
+// as "21:20:00" in a CSV must not pass for tags.
+var (
+	mt940Reference = regexp.MustCompile(`(?m)^[ \t]*:20:`)
+	mt940Header    = regexp.MustCompile(`(?m)^[ \t]*:(?:25|60F):`)
+	mt940Entry     = regexp.MustCompile(`(?m)^[ \t]*:61:`)
+)
+
+// sniffFormat guesses the statement format from the first bytes of a file,
+// falling back to CSV.
+func sniffFormat(head []byte) string {
+	if bytes.HasPrefix(head, []byte("PK\x03\x04")) {
+		return FormatXLSX // Office Open XML is a zip archive
+	}
+
+	upper := bytes.ToUpper(bytes.TrimSpace(head))
+	switch {
+	case bytes.Contains(upper, []byte("OFXHEADER")), bytes.Contains(upper, []byte("<OFX>")):
+		return FormatOFX
+	case bytes.Contains(upper, []byte("CAMT.053")), bytes.Contains(upper, []byte("<BKTOCSTMRSTMT")):
+		return FormatCamt053
+	case bytes.Contains(upper, []byte("<SMSES")):
+		return FormatSMS
+	case mt940Reference.Match(upper) && mt940Header.Match(upper) && mt940Entry.Match(upper):
+		return FormatMT940
+	case bytes.HasPrefix(upper, []byte("!TYPE:")), bytes.HasPrefix(upper, []byte("!ACCOUNT")):
+		return FormatQIF
+	case isJSONArray(upper):
+		return FormatJSON
+	case bytes.HasPrefix(upper, []byte("{")):
+		return FormatNDJSON
+	default:
+		return FormatCSV
+	}
+}
+
+// isJSONArray reports whether head opens an array of objects, or an empty one.
+// A bare "[" is not enough: some exports bracket their CSV column names, as in
+// "[Date],[Narration],[Amount]".
+func isJSONArray(head []byte) bool {
+	rest, ok := bytes.CutPrefix(head, []byte("["))
+	if !ok {
+		return false
+	}
+	rest = bytes.TrimLeft(rest, " \t\r\n")
+	return len(rest) > 0 && (rest[0] == '{' || rest[0] == ']')
+}
+
+// delimiterSniffLen is how much of a CSV is inspected to guess its delimiter;
+// bank exports can carry a long preamble before the first data row.
+const delimiterSniffLen = 32 << 10
+
+// csvDelimiters are the separators sniffDelimiter considers, in order of preference.
+var csvDelimiters = []rune{',', ';', '\t', '|'}
+
+// sniffDelimiter picks the separator that splits the most lines into the same
+// number of fields, and reports whether fields are quoted. Excel's "sep=;" hint
+// line wins outright.
+func sniffDelimiter(head []byte) (rune, bool) {
+	lines := strings.Split(strings.ReplaceAll(string(head), "\r", ""), "\n")
+	if len(lines) > 1 && len(head) >= delimiterSniffLen {
+		lines = lines[:len(lines)-1] // Cut off mid-line
+	}
+
+	delimiter := csvDelimiters[0]
+	if first := strings.TrimSpace(lines[0]); strings.HasPrefix(strings.ToLower(first), "sep=") && len(first) == 5 {
+		delimiter = rune(first[4])
+	} else {
+		bestLines, bestFields := 0, 0
+		for _, d := range csvDelimiters {
+			widths := make(map[int]int)
+			for _, line := range lines {
+				if n := countUnquoted(line, d); n > 0 {
+					widths[n]++
+				}
+			}
+			for fields, n := range widths {
+				if n > bestLines || (n == bestLines && fields > bestFields) {
+					delimiter, bestLines, bestFields = d, n, fields
+				}
+			}
+		}
+	}
+
+	quoted := false
+	for _, line := range lines {
+		if strings.HasPrefix(line, `"`) || strings.Contains(line, string(delimiter)+`"`) {
+			quoted = true
+			break
+		}
+	}
+	return delimiter, quoted
+}
+
+// countUnquoted counts d in line outside double-quoted sections.
+func countUnquoted(line string, d rune) int {
+	n, inQuotes := 0, false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == d && !inQuotes:
+			n++
+		}
+	}
+	return n
+}
+
+// delimiterName is how a delimiter is shown in ParseReport and accepted in ParseOptions.
+func delimiterName(d rune) string {
+	if d == '\t' {
+		return "tab"
+	}
+	return string(d)
+}
+
+// foldDescription builds one description line from the counterparty and
+// remittance fields of formats that keep them apart, noting the value date when
+// it differs from the booking date.
+func foldDescription(counterparty, remittance, bookingDate, valueDate string) string {
+	var parts []string
+	for _, part := range []string{counterparty, remittance} {
+		if part = strings.Join(strings.Fields(part), " "); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	description := strings.Join(parts, " - ")
+	if valueDate != "" && valueDate != bookingDate {
+		description += " (value date " + valueDate + ")"
+	}
+	return strings.TrimSpace(description)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// direction splits an amount signed from the spender's side (positive is money
+// out) into its size and kind.
+func direction(amount float64) (float64, string) {
+	if amount < 0 {
+		return -amount, models.KindCredit
+	}
+	return amount, models.KindDebit
+}
@@ -0,0 +1,24 @@
+package services
+
+import "testing"
+
+func TestSniffFormat(t *testing.T) {
+	tests := []struct {
+		head, want string
+	}{
+		{`[{"date": "2026-01-05", "amount": 450}]`, FormatJSON},
+		{"  [\n  {\"date\": \"2026-01-05\"}", FormatJSON},
+		{"[ ]", FormatJSON},
+		{`{"date": "2026-01-05", "amount": 450}`, FormatNDJSON},
+		{"[Date],[Narration],[Amount]\n05/01/2026,Swiggy,450.00", FormatCSV},
+		{"[Date];[Amount]\n05/01/2026;450,00", FormatCSV},
+		{"Date,Narration,Amount\n05/01/2026,Swiggy,450.00", FormatCSV},
+		{"!Type:Bank\nD01/05/2026\nT-450.00\n^", FormatQIF},
+		{"OFXHEADER:100\nDATA:OFXSGML", FormatOFX},
+	}
+	for _, tt := range tests {
+		if got := sniffFormat([]byte(tt.head)); got != tt.want {
+			t.Errorf("sniffFormat(%q) = %s, want %s", tt.head, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+// DefaultBaseCurrency is the currency insights are reported in until the user
+// picks another one, and the currency of statements that do not name theirs.
+const DefaultBaseCurrency = "INR"
+
+// SkipNoFXRate counts transactions left out of an import because the rate
+// table has no rate for their currency on their date.
+const SkipNoFXRate = "no_fx_rate"
+
+// fxMaxAge is how old the latest rate before a transaction may be. Daily
+// tables skip weekends and bank holidays; monthly averages cover a month.
+const fxMaxAge = 31 * 24 * time.Hour
+
+// NormalizeCurrency checks that code looks like an ISO 4217 code and returns
+// it in upper case.
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.TrimSpace(code)
+	if !currencyCode.MatchString(code) {
+		return "", fmt.Errorf("invalid currency %q, want an ISO code such as INR or USD", code)
+	}
+	return strings.ToUpper(code), nil
+}
+
+// fxRate is the value of one unit of a currency in another on a date.
+type fxRate struct {
+	date time.Time
+	rate float64
+}
+
+// FXService converts amounts between currencies with a table of rates loaded
+// from a local CSV or JSON file. Rates are kept per currency pair and sorted by
+// date; a transaction uses the latest rate on or before its date.
+type FXService struct {
+	mu    sync.RWMutex
+	rates map[[2]string][]fxRate // {from, to} -> rates by date
+}
+
+func NewFXService() *FXService {
+	return &FXService{rates: make(map[[2]string][]fxRate)}
+}
+
+// fxRecord is one row of a rate table: one unit of Currency is worth Rate units
+// of Base on Date.
+type fxRecord struct {
+	Date     string  `json:"date"`
+	Currency string  `json:"currency"`
+	Base     string  `json:"base"`
+	Rate     float64 `json:"rate"`
+}
+
+// Load reads a rate table and adds its rates to the ones already loaded or,
+// with replace, swaps them out. A rate for a pair and date that is already
+// loaded is overwritten. CSV tables have a header naming date, currency and
+// rate columns, plus an optional base column (DefaultBaseCurrency when absent):
+//
+//	date,currency,base,rate
+//	2026-01-05,USD,INR,85.72
+//
+// JSON tables are an array of the same records, or rates grouped by date:
+//
+//	{"base": "INR", "rates": {"2026-01-05": {"USD": 85.72, "EUR": 99.10}}}
+//
+// Dates are YYYY-MM-DD, or YYYY-MM for a monthly rate. Load returns the number
+// of rates read; on error the table is left as it was.
+func (s *FXService) Load(r io.Reader, replace bool) (int, error) {
+	text, err := newTextReader(bufio.NewReader(r), "")
+	if err != nil {
+		return 0, err
+	}
+	br := bufio.NewReader(text)
+	var records []fxRecord
+	if first, err := peekNonSpace(br); err == nil && (first == '[' || first == '{') {
+		records, err = readFXJSON(br)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		records, err = readFXCSV(br)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	rates := make(map[[2]string][]fxRate)
+	for i, rec := range records {
+		from, to := strings.ToUpper(strings.TrimSpace(rec.Currency)), strings.ToUpper(strings.TrimSpace(rec.Base))
+		if to == "" {
+			to = DefaultBaseCurrency
+		}
+		if !currencyCode.MatchString(from) || !currencyCode.MatchString(to) {
+			return 0, fmt.Errorf("rate %d: unrecognised currency pair %q/%q", i+1, rec.Currency, rec.Base)
+		}
+		if from == to {
+			continue
+		}
+		date, err := parseFXDate(rec.Date)
+		if err != nil {
+			return 0, fmt.Errorf("rate %d: unrecognised date %q, want YYYY-MM-DD or YYYY-MM", i+1, rec.Date)
+		}
+		if rec.Rate <= 0 || math.IsInf(rec.Rate, 0) || math.IsNaN(rec.Rate) {
+			return 0, fmt.Errorf("rate %d: %s/%s rate must be positive", i+1, from, to)
+		}
+		pair := [2]string{from, to}
+		rates[pair] = append(rates[pair], fxRate{date, rec.Rate})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if replace {
+		s.rates = make(map[[2]string][]fxRate)
+	}
+	for pair, added := range rates {
+		all := append(slices.Clone(s.rates[pair]), added...)
+		slices.SortStableFunc(all, func(a, b fxRate) int { return a.date.Compare(b.date) })
+		// Of several rates for one date, the one loaded last wins.
+		var merged []fxRate
+		for _, r := range all {
+			if n := len(merged); n > 0 && merged[n-1].date.Equal(r.date) {
+				merged[n-1] = r
+				continue
+			}
+			merged = append(merged, r)
+		}
+		s.rates[pair] = merged
+	}
+	return len(records), nil
+}
+
+// readFXCSV reads the records of a CSV rate table.
+func readFXCSV(r io.Reader) ([]fxRecord, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading rate table header: %v", err)
+	}
+	cols := map[string]int{"date": -1, "currency": -1, "base": -1, "rate": -1}
+	for i, name := range header {
+		if _, ok := cols[normalizeHeader(name)]; ok {
+			cols[normalizeHeader(name)] = i
+		}
+	}
+	for _, name := range []string{"date", "currency", "rate"} {
+		if cols[name] < 0 {
+			return nil, fmt.Errorf("rate table is missing the %s column", name)
+		}
+	}
+
+	var records []fxRecord
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.Join(row, "") == "" {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		raw := cell(row, cols["rate"])
+		rate, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: unrecognised rate %q", line, raw)
+		}
+		records = append(records, fxRecord{
+			Date:     cell(row, cols["date"]),
+			Currency: cell(row, cols["currency"]),
+			Base:     cell(row, cols["base"]),
+			Rate:     rate,
+		})
+	}
+}
+
+// readFXJSON reads the records of a JSON rate table in either layout.
+func readFXJSON(r io.Reader) ([]fxRecord, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var records []fxRecord
+	if err := json.Unmarshal(data, &records); err == nil {
+		return records, nil
+	}
+	var table struct {
+		Base  string                        `json:"base"`
+		Rates map[string]map[string]float64 `json:"rates"`
+	}
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("invalid rate table: %v", err)
+	}
+	for date, byCurrency := range table.Rates {
+		for currency, rate := range byCurrency {
+			records = append(records, fxRecord{Date: date, Currency: currency, Base: table.Base, Rate: rate})
+		}
+	}
+	return records, nil
+}
+
+func parseFXDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01", s)
+}
+
+// Rate returns what one unit of from is worth in to on date. Pairs the table
+// holds the other way round are inverted, and pairs it lacks are crossed
+// through a currency both sides have rates against, as USD to EUR through INR.
+func (s *FXService) Rate(from, to string, date time.Time) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if rate, ok := s.lookup(from, to, date); ok {
+		return rate, nil
+	}
+	var vias []string
+	for pair := range s.rates {
+		for _, c := range pair {
+			if c != from && c != to && !slices.Contains(vias, c) {
+				vias = append(vias, c)
+			}
+		}
+	}
+	slices.Sort(vias)
+	for _, via := range vias {
+		first, ok := s.lookup(from, via, date)
+		if !ok {
+			continue
+		}
+		if second, ok := s.lookup(via, to, date); ok {
+			return first * second, nil
+		}
+	}
+	return 0, fmt.Errorf("no %s to %s rate on or before %s", from, to, date.Format("2006-01-02"))
+}
+
+// lookup finds a direct or inverted rate no older than fxMaxAge. Callers hold mu.
+func (s *FXService) lookup(from, to string, date time.Time) (float64, bool) {
+	if rate, ok := latestRate(s.rates[[2]string{from, to}], date); ok {
+		return rate, true
+	}
+	if rate, ok := latestRate(s.rates[[2]string{to, from}], date); ok {
+		return 1 / rate, true
+	}
+	return 0, false
+}
+
+func latestRate(rates []fxRate, date time.Time) (float64, bool) {
+	i, found := slices.BinarySearchFunc(rates, date, func(r fxRate, t time.Time) int { return r.date.Compare(t) })
+	if !found {
+		i-- // The latest rate before date
+	}
+	if i < 0 || date.Sub(rates[i].date) > fxMaxAge {
+		return 0, false
+	}
+	return rates[i].rate, true
+}
+
+// Pairs lists the loaded currency pairs with the dates their rates cover.
+func (s *FXService) Pairs() []models.FXPair {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pairs := []models.FXPair{}
+	for pair, rates := range s.rates {
+		pairs = append(pairs, models.FXPair{
+			Currency: pair[0],
+			Base:     pair[1],
+			Rates:    len(rates),
+			From:     rates[0].date.Format("2006-01-02"),
+			To:       rates[len(rates)-1].date.Format("2006-01-02"),
+		})
+	}
+	slices.SortFunc(pairs, func(a, b models.FXPair) int {
+		return strings.Compare(a.Currency+"/"+a.Base, b.Currency+"/"+b.Base)
+	})
+	return pairs
+}
+
+// Convert restates an expense in base. Amount always holds the value in the
+// base currency; OriginalAmount keeps the amount the statement gave when its
+// Currency is a different one. Converting again to another base starts from
+// the original amount, so nothing drifts.
+func (s *FXService) Convert(e *models.Expense, base string) error {
+	original := originalAmount(*e)
+	if e.Currency == "" {
+		e.Currency = base
+	}
+	rate, err := s.Rate(e.Currency, base, calendarDay(e.Date))
+	if err != nil {
+		return err
+	}
+	if e.Currency == base {
+		e.Amount, e.OriginalAmount = original, 0
+		return nil
+	}
+	e.Amount = math.Round(original*rate*100) / 100
+	e.OriginalAmount = original
+	return nil
+}
+
+// ConvertExpenses restates every expense in base. It stops at the first one
+// without a rate and leaves the slice unchanged.
+func (s *FXService) ConvertExpenses(expenses []models.Expense, base string) error {
+	converted := slices.Clone(expenses)
+	for i := range converted {
+		if err := s.Convert(&converted[i], base); err != nil {
+			return fmt.Errorf("%s %s: %v", converted[i].Day(), converted[i].Description, err)
+		}
+	}
+	copy(expenses, converted)
+	return nil
+}
+
+// ConvertImport restates the transactions of one uploaded file in base.
+// Transactions the statement gave no currency for are taken to be in
+// currency. Those without a rate are dropped and counted in the report under
+// SkipNoFXRate, one issue per currency, so the file can be uploaded again once
+// the rates are loaded.
+func (s *FXService) ConvertImport(expenses []models.Expense, report *models.ParseReport, currency, base string) []models.Expense {
+	kept := expenses[:0]
+	missing := make(map[string]int)
+	firstErr := make(map[string]error)
+	var currencies []string // Currencies without a rate, in order of appearance
+	for _, e := range expenses {
+		if e.Currency == "" {
+			e.Currency = currency
+		}
+		if err := s.Convert(&e, base); err != nil {
+			if missing[e.Currency] == 0 {
+				currencies = append(currencies, e.Currency)
+				firstErr[e.Currency] = err
+			}
+			missing[e.Currency]++
+			continue
+		}
+		kept = append(kept, e)
+	}
+
+	for _, c := range currencies {
+		n := missing[c]
+		report.RowsImported -= n
+		report.Skipped[SkipNoFXRate] += n
+		if len(report.Issues) >= maxReportIssues {
+			report.IssuesTruncated = true
+			continue
+		}
+		report.Issues = append(report.Issues, models.ParseIssue{
+			Level:   "error",
+			Reason:  SkipNoFXRate,
+			Message: fmt.Sprintf("%d %s transaction(s) left out: %s; load rates and upload again", n, c, firstErr[c]),
+		})
+	}
+	return kept
+}
+
+// originalAmount is an expense's amount in its own currency.
+func originalAmount(e models.Expense) float64 {
+	if e.OriginalAmount != 0 {
+		return e.OriginalAmount
+	}
+	return e.Amount
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"fmt"
 	"math"
+	"time"
 
 	"github.com/siddhartharajbongshi/spendsense-backend/models"
 )
@@ -13,13 +14,33 @@ func NewInsightService() *InsightService {
 	return &InsightService{}
 }
 
-func (s *InsightService) GenerateInsights(expenses []models.Expense) []models.Insight {
+// GenerateInsights works out the insights for a set of transactions whose
+// amounts are in currency, the user's base currency. A MonthlyCost that sums
+// spending is the average over the calendar months the transactions cover.
+func (s *InsightService) GenerateInsights(expenses []models.Expense, currency string) []models.Insight {
+	money := func(amount float64) string { return formatMoney(amount, currency) }
+	months := float64(monthsCovered(expenses))
+	monthly := func(total float64) float64 { return math.Round(total/months*100) / 100 }
 	categoryTotals := make(map[string]float64)
-	var totalSpent float64
+	var totalSpent, totalIncome float64
+	var spendCount int
 
 	for _, exp := range expenses {
+		if exp.IsTransfer() {
+			continue
+		}
+		if exp.IsRefund() {
+			categoryTotals[exp.Category] -= exp.Amount
+			totalSpent -= exp.Amount
+			continue
+		}
+		if exp.IsCredit() {
+			totalIncome += exp.Amount
+			continue
+		}
 		categoryTotals[exp.Category] += exp.Amount
 		totalSpent += exp.Amount
+		spendCount++
 	}
 
 	var insights []models.Insight
@@ -37,9 +58,9 @@ func (s *InsightService) GenerateInsights(expenses []models.Expense) []models.In
 		topPercentage := (topAmount / totalSpent) * 100
 		insights = append(insights, models.Insight{
 			Type:        "top_spending",
-			MonthlyCost: math.Round(topAmount*100) / 100,
+			MonthlyCost: monthly(topAmount),
 			Percentage:  math.Round(topPercentage*10) / 10,
-			Message:     fmt.Sprintf("Your biggest expense is %s (₹%.0f, %.1f%%)", topCategory, topAmount, topPercentage),
+			Message:     fmt.Sprintf("Your biggest expense is %s (%s, %.1f%%)", topCategory, money(topAmount), topPercentage),
 			FlagLevel:   "info",
 		})
 	}
@@ -57,9 +78,9 @@ func (s *InsightService) GenerateInsights(expenses []models.Expense) []models.In
 
 		insights = append(insights, models.Insight{
 			Type:        "subscription_waste",
-			MonthlyCost: math.Round(subscriptions*100) / 100,
+			MonthlyCost: monthly(subscriptions),
 			Percentage:  math.Round(subPercentage*10) / 10,
-			Message:     fmt.Sprintf("Subscriptions: ₹%.0f/month (%.1f%% of spend)", subscriptions, subPercentage),
+			Message:     fmt.Sprintf("Subscriptions: %s/month (%.1f%% of spend)", money(monthly(subscriptions)), subPercentage),
 			FlagLevel:   flag,
 		})
 	}
@@ -77,20 +98,20 @@ func (s *InsightService) GenerateInsights(expenses []models.Expense) []models.In
 
 		insights = append(insights, models.Insight{
 			Type:        "high_food",
-			MonthlyCost: math.Round(food*100) / 100,
+			MonthlyCost: monthly(food),
 			Percentage:  math.Round(foodPercentage*10) / 10,
-			Message:     fmt.Sprintf("Food & Dining: ₹%.0f/month (%.1f%% of spend)", food, foodPercentage),
+			Message:     fmt.Sprintf("Food & Dining: %s/month (%.1f%% of spend)", money(monthly(food)), foodPercentage),
 			FlagLevel:   flag,
 		})
 	}
 
 	// Insight 4: Daily Average
-	if len(expenses) > 0 {
-		dailyAvg := totalSpent / float64(len(expenses))
+	if spendCount > 0 {
+		dailyAvg := totalSpent / float64(spendCount)
 		insights = append(insights, models.Insight{
 			Type:        "daily_average",
 			MonthlyCost: math.Round(dailyAvg*100) / 100,
-			Message:     fmt.Sprintf("Daily spending average per transaction: ₹%.0f", dailyAvg),
+			Message:     fmt.Sprintf("Daily spending average per transaction: %s", money(dailyAvg)),
 			FlagLevel:   "info",
 		})
 	}
@@ -118,25 +139,118 @@ func (s *InsightService) GenerateInsights(expenses []models.Expense) []models.In
 
 		insights = append(insights, models.Insight{
 			Type:        "fixed_vs_variable",
-			MonthlyCost: math.Round(fixedTotal*100) / 100, // Showing fixed cost as the primary metric
+			MonthlyCost: monthly(fixedTotal), // Showing fixed cost as the primary metric
 			Percentage:  math.Round(fixedPct*10) / 10,
 			Message:     msg,
 			FlagLevel:   "info",
 		})
 	}
 
-	// Insight 5: Category Breakdown
+	// Insight 6: Income, Net Cash Flow and Savings Rate
+	if totalIncome > 0 {
+		net := totalIncome - totalSpent
+		savingsRate := (net / totalIncome) * 100
+
+		insights = append(insights, models.Insight{
+			Type:        "income",
+			MonthlyCost: monthly(totalIncome),
+			Message:     fmt.Sprintf("Money in: %s", money(totalIncome)),
+			FlagLevel:   "info",
+		})
+
+		netMsg := fmt.Sprintf("You kept %s after spending", money(net))
+		netFlag := "info"
+		if net < 0 {
+			netMsg = fmt.Sprintf("You spent %s more than you earned", money(-net))
+			netFlag = "warning"
+		}
+		insights = append(insights, models.Insight{
+			Type:        "net_cash_flow",
+			MonthlyCost: monthly(net),
+			Message:     netMsg,
+			FlagLevel:   netFlag,
+		})
+
+		flag := "info"
+		if savingsRate < 0 {
+			flag = "alert"
+		} else if savingsRate < 20 {
+			flag = "warning"
+		}
+		insights = append(insights, models.Insight{
+			Type:        "savings_rate",
+			MonthlyCost: monthly(net),
+			Percentage:  math.Round(savingsRate*10) / 10,
+			Message:     fmt.Sprintf("Savings rate: %.1f%% of income", savingsRate),
+			FlagLevel:   flag,
+		})
+	}
+
+	// Insight 7: Late-night Spending, from transactions that carry a time
+	pattern := spendingPatternOf(expenses)
+	if pattern.lateNight > 0 {
+		share := pattern.lateNight / pattern.timedSpend * 100
+		flag := "info"
+		if share > 20 {
+			flag = "alert"
+		} else if share > 10 {
+			flag = "warning"
+		}
+		noun := "transactions"
+		if pattern.lateNightCount == 1 {
+			noun = "transaction"
+		}
+		insights = append(insights, models.Insight{
+			Type:        "late_night",
+			MonthlyCost: monthly(pattern.lateNight),
+			Percentage:  math.Round(share*10) / 10,
+			Message:     fmt.Sprintf("Late-night spending (11 PM–4 AM): %s across %d %s (%.1f%% of timed spend)", money(pattern.lateNight), pattern.lateNightCount, noun, share),
+			FlagLevel:   flag,
+		})
+	}
+
+	// Insight 8: Weekend vs Weekday, per calendar day so a month with five
+	// weekends does not look worse
+	if pattern.weekendDays > 0 && pattern.weekdayDays > 0 && pattern.weekend+pattern.weekday > 0 {
+		weekendDaily := pattern.weekend / float64(pattern.weekendDays)
+		weekdayDaily := pattern.weekday / float64(pattern.weekdayDays)
+		flag := "info"
+		if weekdayDaily > 0 && weekendDaily > 2*weekdayDaily {
+			flag = "warning"
+		}
+		insights = append(insights, models.Insight{
+			Type:        "weekend_spending",
+			MonthlyCost: monthly(pattern.weekend),
+			Percentage:  math.Round(pattern.weekend/(pattern.weekend+pattern.weekday)*1000) / 10,
+			Message:     fmt.Sprintf("Weekends cost %s a day vs %s on weekdays", money(weekendDaily), money(weekdayDaily)),
+			FlagLevel:   flag,
+		})
+	}
+
+	// Insight 9: Priciest Day of the Week
+	if day, avg := pattern.peakWeekday(); avg > 0 {
+		insights = append(insights, models.Insight{
+			Type:        "weekday_peak",
+			MonthlyCost: math.Round(avg*100) / 100,
+			Message:     fmt.Sprintf("%s is your priciest day: %s on average", day, money(avg)),
+			FlagLevel:   "info",
+		})
+	}
+
+	// Insight 10: Category Breakdown
 	breakdown := make(map[string]float64)
 	for k, v := range categoryTotals {
-		breakdown[k] = math.Round(v*100) / 100
+		if v := math.Round(v*100) / 100; v > 0 {
+			breakdown[k] = v // Categories fully refunded drop out
+		}
 	}
 	insights = append(insights, models.Insight{
 		Type:           "category_breakdown",
-		MonthlyCost:    math.Round(totalSpent*100) / 100,
+		MonthlyCost:    monthly(totalSpent),
 		Message:        "Here's where your money goes",
 		Breakdown:      breakdown,
 		FlagLevel:      "info",
-		ImpactContext:  fmt.Sprintf("Total Yearly Spend: ₹%.0f", totalSpent*12),
+		ImpactContext:  fmt.Sprintf("Total Yearly Spend: %s", money(monthly(totalSpent)*12)),
 		ActionableStep: "Check if this aligns with your goals.",
 	})
 
@@ -146,11 +260,11 @@ func (s *InsightService) GenerateInsights(expenses []models.Expense) []models.In
 			switch insights[i].Type {
 			case "subscription_waste":
 				yearlyLoss := insights[i].MonthlyCost * 12
-				insights[i].ImpactContext = fmt.Sprintf("You lose ₹%.0f/year — that's a weekend trip!", yearlyLoss)
+				insights[i].ImpactContext = fmt.Sprintf("You lose %s/year — that's a weekend trip!", money(yearlyLoss))
 				insights[i].ActionableStep = "Cancel at least 1 unused sub today."
 			case "high_food":
 				saved := insights[i].MonthlyCost * 0.20 // Assume 20% saving target
-				insights[i].ImpactContext = fmt.Sprintf("Cooking more could save you ₹%.0f/month.", saved)
+				insights[i].ImpactContext = fmt.Sprintf("Cooking more could save you %s/month.", money(saved))
 				insights[i].ActionableStep = "Limit ordering out to weekends only."
 			case "fixed_vs_variable":
 				insights[i].ImpactContext = "High fixed costs limit your freedom to invest."
@@ -161,16 +275,131 @@ func (s *InsightService) GenerateInsights(expenses []models.Expense) []models.In
 			case "daily_average":
 				insights[i].ImpactContext = "Small daily habits add up."
 				insights[i].ActionableStep = "Try a 'No Spend Day' once a week."
+			case "income":
+				insights[i].ImpactContext = "Everything else is measured against this."
+				insights[i].ActionableStep = "Move savings out on payday, before you spend."
+			case "net_cash_flow":
+				if insights[i].MonthlyCost < 0 {
+					insights[i].ImpactContext = "The gap is coming out of savings or credit."
+					insights[i].ActionableStep = "Cut your top category until this turns positive."
+				} else {
+					insights[i].ImpactContext = fmt.Sprintf("Invested, that's %s a year.", money(insights[i].MonthlyCost*12))
+					insights[i].ActionableStep = "Set up an automatic transfer for this amount."
+				}
+			case "savings_rate":
+				insights[i].ImpactContext = "A 20% savings rate is a healthy baseline."
+				insights[i].ActionableStep = "Raise it by 5% next month."
+			case "late_night":
+				insights[i].ImpactContext = "Late-night buys are mostly delivery and impulse — the easiest spend to cut."
+				insights[i].ActionableStep = "Set an 11 PM cut-off: log out of delivery apps before bed."
+			case "weekend_spending":
+				insights[i].ImpactContext = fmt.Sprintf("Weekends take %.0f%% of your spend.", insights[i].Percentage)
+				insights[i].ActionableStep = "Plan one free weekend activity before Friday."
+			case "weekday_peak":
+				insights[i].ImpactContext = "Spending clusters on the same day each week."
+				insights[i].ActionableStep = "Set a budget for that day and check it that morning."
 			}
 		}
 	}
 
-	if len(insights) > 6 {
-		return insights[:6]
+	if len(insights) > 12 {
+		return insights[:12]
 	}
 	return insights
 }
 
+// monthsCovered counts the calendar months with transactions, at least one, so
+// totals can be stated per month.
+func monthsCovered(expenses []models.Expense) int {
+	seen := make(map[string]bool)
+	for _, e := range expenses {
+		if !e.IsTransfer() {
+			seen[e.Date.Format("2006-01")] = true
+		}
+	}
+	return max(len(seen), 1)
+}
+
+// Late night runs from lateNightStart to lateNightEnd, clock hours.
+const (
+	lateNightStart = 23
+	lateNightEnd   = 4
+)
+
+// spendingPattern summarizes when money is spent. Only purchases count:
+// refunds and transfers say nothing about habits.
+type spendingPattern struct {
+	lateNight      float64 // Spend late at night
+	lateNightCount int
+	timedSpend     float64 // Spend on transactions that carry a time of day
+	weekend        float64
+	weekday        float64
+	weekendDays    int // Calendar days of each kind from the first to the last transaction
+	weekdayDays    int
+	byWeekday      [7]float64
+	weekdayCount   [7]int // How often each day of the week occurs in the period
+}
+
+func spendingPatternOf(expenses []models.Expense) spendingPattern {
+	var p spendingPattern
+	var first, last time.Time
+	for _, exp := range expenses {
+		if exp.Date.IsZero() {
+			continue
+		}
+		day := calendarDay(exp.Date)
+		if first.IsZero() || day.Before(first) {
+			first = day
+		}
+		if day.After(last) {
+			last = day
+		}
+		if exp.IsCredit() || exp.IsTransfer() {
+			continue
+		}
+		if exp.HasTime() {
+			p.timedSpend += exp.Amount
+			if h := exp.Date.Hour(); h >= lateNightStart || h < lateNightEnd {
+				p.lateNight += exp.Amount
+				p.lateNightCount++
+			}
+		}
+		weekday := exp.Date.Weekday()
+		if weekday == time.Saturday || weekday == time.Sunday {
+			p.weekend += exp.Amount
+		} else {
+			p.weekday += exp.Amount
+		}
+		p.byWeekday[weekday] += exp.Amount
+	}
+	for day := first; !first.IsZero() && !day.After(last); day = day.AddDate(0, 0, 1) {
+		weekday := day.Weekday()
+		if weekday == time.Saturday || weekday == time.Sunday {
+			p.weekendDays++
+		} else {
+			p.weekdayDays++
+		}
+		p.weekdayCount[weekday]++
+	}
+	return p
+}
+
+// peakWeekday is the day of the week with the highest average spend. It needs
+// at least two of every day of the week, so one big Tuesday is not a pattern.
+func (p spendingPattern) peakWeekday() (time.Weekday, float64) {
+	var peak time.Weekday
+	var best float64
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if p.weekdayCount[day] < 2 {
+			return 0, 0
+		}
+		if avg := p.byWeekday[day] / float64(p.weekdayCount[day]); avg > best {
+			peak, best = day, avg
+		}
+	}
+	return peak, best
+}
+
 func (s *InsightService) CalculateConfidenceScore(insights []models.Insight) int {
 	score := 100
 	for _, insight := range insights {
@@ -186,37 +415,90 @@ func (s *InsightService) CalculateConfidenceScore(insights []models.Insight) int
 	return score
 }
 
-func (s *InsightService) GenerateDashboardData(expenses []models.Expense) models.DashboardData {
-	var total float64
+func (s *InsightService) GenerateDashboardData(expenses []models.Expense, currency string) models.DashboardData {
+	var total, income float64
+	var count, transfers int
+	var refunds float64
+	var unmatched []models.Expense
 	for _, exp := range expenses {
+		if exp.IsTransfer() {
+			transfers++
+			continue
+		}
+		if exp.IsRefund() {
+			refunds += exp.Amount
+			continue
+		}
+		if isUnmatchedRefund(exp) {
+			unmatched = append(unmatched, exp)
+		}
+		if exp.IsCredit() {
+			income += exp.Amount
+			continue
+		}
 		total += exp.Amount
+		count++
 	}
+	total -= refunds
 
 	avgDaily := 0.0
-	if len(expenses) > 0 {
-		avgDaily = total / float64(len(expenses))
+	if count > 0 {
+		avgDaily = total / float64(count)
+	}
+	savingsRate := 0.0
+	if income > 0 {
+		savingsRate = (income - total) / income * 100
 	}
 
-	insights := s.GenerateInsights(expenses)
+	insights := s.GenerateInsights(expenses, currency)
 	confidenceScore := s.CalculateConfidenceScore(insights)
 
 	return models.DashboardData{
+		BaseCurrency:     currency,
 		TotalExpenses:    math.Round(total*100) / 100,
-		ExpenseCount:     len(expenses),
+		TotalIncome:      math.Round(income*100) / 100,
+		NetCashFlow:      math.Round((income-total)*100) / 100,
+		SavingsRate:      math.Round(savingsRate*10) / 10,
+		ExpenseCount:     count,
+		TransferCount:    transfers,
+		RefundsNetted:    math.Round(refunds*100) / 100,
+		UnmatchedRefunds: unmatched,
 		AverageDaily:     math.Round(avgDaily*100) / 100,
 		Expenses:         expenses,
 		Insights:         insights,
 		MonthlyBreakdown: s.GetMonthlyBreakdown(expenses),
+		AccountBreakdown: s.GetAccountBreakdown(expenses),
 		ConfidenceScore:  confidenceScore,
 	}
 }
 
+// GetAccountBreakdown totals spend per source account. It is nil when no
+// transaction names its account.
+func (s *InsightService) GetAccountBreakdown(expenses []models.Expense) map[string]float64 {
+	var breakdown map[string]float64
+	for _, exp := range expenses {
+		if exp.IsCredit() && !exp.IsRefund() || exp.IsTransfer() || exp.Account == "" {
+			continue
+		}
+		if breakdown == nil {
+			breakdown = make(map[string]float64)
+		}
+		breakdown[exp.Account] += signedSpend(exp)
+	}
+	for k, v := range breakdown {
+		breakdown[k] = math.Round(v*100) / 100
+	}
+	return breakdown
+}
+
 func (s *InsightService) GetMonthlyBreakdown(expenses []models.Expense) map[string]float64 {
 	breakdown := make(map[string]float64)
 	for _, exp := range expenses {
-		if len(exp.Date) >= 7 {
-			monthKey := exp.Date[:7] // YYYY-MM
-			breakdown[monthKey] += exp.Amount
+		if exp.IsCredit() && !exp.IsRefund() || exp.IsTransfer() {
+			continue
+		}
+		if !exp.Date.IsZero() {
+			breakdown[exp.Date.Format("2006-01")] += signedSpend(exp)
 		}
 	}
 	// Round values
@@ -225,3 +507,12 @@ func (s *InsightService) GetMonthlyBreakdown(expenses []models.Expense) map[stri
 	}
 	return breakdown
 }
+
+// signedSpend is what a transaction adds to spend: a purchase its amount, a
+// linked refund the amount given back.
+func signedSpend(exp models.Expense) float64 {
+	if exp.IsRefund() {
+		return -exp.Amount
+	}
+	return exp.Amount
+}
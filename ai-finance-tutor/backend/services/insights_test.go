@@ -0,0 +1,38 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+func TestInsightsPerMonth(t *testing.T) {
+	var expenses []models.Expense
+	for m := time.January; m <= time.March; m++ {
+		day := time.Date(2026, m, 10, 0, 0, 0, 0, time.UTC)
+		expenses = append(expenses,
+			models.Expense{Date: day, Description: "Netflix", Amount: 600, Kind: models.KindDebit, Category: "Subscriptions"},
+			models.Expense{Date: day, Description: "Swiggy", Amount: 2400, Kind: models.KindDebit, Category: "Food"},
+		)
+	}
+	// Moving money between accounts adds no month.
+	expenses = append(expenses, models.Expense{
+		Date: time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC), Description: "To savings",
+		Amount: 5000, Kind: models.KindDebit, Category: "Transfer", TransferOf: "x",
+	})
+
+	want := map[string]float64{
+		"subscription_waste": 600,
+		"high_food":          2400,
+		"category_breakdown": 3000,
+	}
+	for _, in := range NewInsightService().GenerateInsights(expenses, "INR") {
+		if cost, ok := want[in.Type]; ok && in.MonthlyCost != cost {
+			t.Errorf("%s: MonthlyCost = %.2f, want %.2f", in.Type, in.MonthlyCost, cost)
+		}
+		if in.Type == "category_breakdown" && in.ImpactContext != "Total Yearly Spend: "+formatMoney(36000, "INR") {
+			t.Errorf("category_breakdown: ImpactContext = %q", in.ImpactContext)
+		}
+	}
+}
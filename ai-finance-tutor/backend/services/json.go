@@ -0,0 +1,223 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+// jsonTransaction is one record of a JSON or NDJSON import. It has the shape of
+// models.Expense; amount may also be a string such as "₹1,200.00" or "450 Dr".
+// Our own export writes the time of day in timestamp, next to the date, and
+// for foreign-currency spends the amount in currency in original_amount, with
+// amount already restated in the base currency.
+type jsonTransaction struct {
+	Date           string          `json:"date"`
+	Timestamp      string          `json:"timestamp"`
+	Description    string          `json:"description"`
+	Amount         json.RawMessage `json:"amount"`
+	Kind           string          `json:"kind"`
+	Category       string          `json:"category"`
+	TransactionID  string          `json:"transaction_id"`
+	Account        string          `json:"account"`
+	Currency       string          `json:"currency"`
+	OriginalAmount json.RawMessage `json:"original_amount"`
+}
+
+// StreamJSON reads transactions from a JSON array or from newline-delimited JSON
+// objects and calls fn for every one. Records go through the same checks as CSV
+// rows: dates are inferred for the whole file, and records with a zero or
+// unreadable amount or an unreadable date are skipped. Issues are reported
+// against the record number, counting from 1.
+func (p *ParserService) StreamJSON(ctx context.Context, r io.Reader, opts ParseOptions, fn ExpenseFunc) (*models.ParseReport, error) {
+	br := bufio.NewReader(r)
+	format := FormatNDJSON
+	if first, err := peekNonSpace(br); err == nil && first == '[' {
+		format = FormatJSON
+	}
+	rep := newReporter(format)
+	decoder := json.NewDecoder(br)
+	if format == FormatJSON {
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+	}
+
+	dates := newDateInferrer(nil, opts.DateFormat, opts.Locale)
+	type pendingRecord struct {
+		index int
+		txn   jsonTransaction
+	}
+	var pending []pendingRecord
+
+	emit := func(index int, txn jsonTransaction) error {
+		expense, layout, err := jsonExpense(txn, opts.Locale, dates)
+		if err != nil {
+			rep.skip(index, err)
+			return nil
+		}
+		rep.imported(layout)
+		return fn(expense)
+	}
+	flush := func() error {
+		for _, rec := range pending {
+			if err := emit(rec.index, rec.txn); err != nil {
+				return err
+			}
+		}
+		pending = nil
+		return nil
+	}
+
+	for index := 1; ; index++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if format == FormatJSON && !decoder.More() {
+			break
+		}
+
+		var txn jsonTransaction
+		err := decoder.Decode(&txn)
+		if err == io.EOF {
+			break
+		}
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+			// A field of the wrong type; the decoder has moved past the record.
+			if typeErr.Field == "" {
+				rep.skip(index, skipRow(SkipNotTransaction, "record is a %s, not an object", typeErr.Value))
+			} else {
+				rep.skip(index, skipRow(SkipNotTransaction, "%s is a %s, want a %s", typeErr.Field, typeErr.Value, typeErr.Type))
+			}
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %v", index, err)
+		}
+
+		if !dates.decided() {
+			dates.observe(txn.when())
+			pending = append(pending, pendingRecord{index, txn})
+			if dates.decided() {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if err := emit(index, txn); err != nil {
+			return nil, err
+		}
+	}
+
+	rep.dateAlternatives(dates)
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return rep.finish(), nil
+}
+
+// when is the record's date, with its time of day when it has a timestamp.
+func (t jsonTransaction) when() string {
+	return firstNonEmpty(strings.TrimSpace(t.Timestamp), t.Date)
+}
+
+func jsonExpense(txn jsonTransaction, locale string, dates *dateInferrer) (models.Expense, string, error) {
+	if txn.when() == "" && len(txn.Amount) == 0 {
+		return models.Expense{}, "", skipRowWarning(SkipNotTransaction, "record has no date or amount")
+	}
+
+	amt, err := jsonAmount(txn.Amount, locale)
+	if err != nil {
+		return models.Expense{}, "", skipRow(SkipInvalidAmount, "unrecognised amount %s", txn.Amount)
+	}
+	if amt.Value == 0 {
+		return models.Expense{}, "", skipRowWarning(SkipZeroAmount, "amount is zero")
+	}
+
+	date, layout, err := dates.parse(txn.when())
+	if err != nil {
+		return models.Expense{}, "", skipRow(SkipInvalidDate, "date %q does not match %s", txn.when(), describeLayout(layout))
+	}
+
+	// An explicit kind wins; otherwise the sign and any Dr/Cr marker decide,
+	// as for a CSV amount column.
+	amount, kind := direction(amt.Value)
+	if amt.Credit {
+		amount, kind = math.Abs(amt.Value), models.KindCredit
+	}
+	switch strings.ToLower(strings.TrimSpace(txn.Kind)) {
+	case "":
+	case models.KindDebit:
+		amount, kind = math.Abs(amt.Value), models.KindDebit
+	case models.KindCredit:
+		amount, kind = math.Abs(amt.Value), models.KindCredit
+	default:
+		return models.Expense{}, "", skipRow(SkipNotTransaction, "unknown kind %q: want debit or credit", txn.Kind)
+	}
+
+	// The amount in the record's own currency is the one to convert; amount
+	// was converted once already on the way out.
+	if len(txn.OriginalAmount) > 0 && string(txn.OriginalAmount) != "null" {
+		original, err := jsonAmount(txn.OriginalAmount, locale)
+		if err != nil {
+			return models.Expense{}, "", skipRow(SkipInvalidAmount, "unrecognised original_amount %s", txn.OriginalAmount)
+		}
+		if original.Value != 0 {
+			amount = math.Abs(original.Value)
+		}
+	}
+
+	currency := amt.Currency
+	if c := strings.TrimSpace(txn.Currency); c != "" {
+		if !currencyCode.MatchString(c) {
+			return models.Expense{}, "", skipRow(SkipInvalidAmount, "unrecognised currency %q", txn.Currency)
+		}
+		currency = strings.ToUpper(c)
+	}
+
+	return models.Expense{
+		Date:          date,
+		Description:   strings.TrimSpace(txn.Description),
+		Amount:        amount,
+		Kind:          kind,
+		Category:      strings.TrimSpace(txn.Category),
+		TransactionID: strings.TrimSpace(txn.TransactionID),
+		Account:       strings.TrimSpace(txn.Account),
+		Currency:      currency,
+	}, layout, nil
+}
+
+// jsonAmount reads an amount written as a JSON number or as statement text.
+func jsonAmount(raw json.RawMessage, locale string) (parsedAmount, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return parseMoney(text, locale)
+	}
+	value, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil {
+		return parsedAmount{}, err
+	}
+	return parsedAmount{Value: value}, nil
+}
+
+// peekNonSpace returns the first byte that is not white space without
+// consuming it.
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			return b, br.UnreadByte()
+		}
+	}
+}
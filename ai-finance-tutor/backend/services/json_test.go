@@ -0,0 +1,54 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	fx := NewFXService()
+	rates := "date,currency,base,rate\n2026-01-02,USD,INR,90.10\n"
+	if _, err := fx.Load(strings.NewReader(rates), true); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	day := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	want := []models.Expense{
+		{Date: day, Description: "Netflix", Amount: 15.49, Kind: models.KindDebit, Category: "Subscriptions", Currency: "USD"},
+		{Date: day, Description: "Refund", Amount: 12, Kind: models.KindCredit, Category: "Income", Currency: "USD"},
+		{Date: day.Add(20*time.Hour + 15*time.Minute), Description: "Swiggy", Amount: 450, Kind: models.KindDebit, Category: "Food", Currency: "INR"},
+	}
+	if err := fx.ConvertExpenses(want, "INR"); err != nil {
+		t.Fatalf("ConvertExpenses: %v", err)
+	}
+
+	exported, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	got, report, err := NewParserService().ParseReader(context.Background(), bytes.NewReader(exported), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseReader: %v", err)
+	}
+	got = fx.ConvertImport(got, report, "", "INR")
+
+	if len(got) != len(want) {
+		t.Fatalf("imported %d transactions, want %d", len(got), len(want))
+	}
+	for i, e := range got {
+		w := want[i]
+		if e.Amount != w.Amount || e.OriginalAmount != w.OriginalAmount || e.Currency != w.Currency || e.Kind != w.Kind {
+			t.Errorf("%s: got %.2f (%.2f %s, %s), want %.2f (%.2f %s, %s)", w.Description,
+				e.Amount, e.OriginalAmount, e.Currency, e.Kind, w.Amount, w.OriginalAmount, w.Currency, w.Kind)
+		}
+		if !e.Date.Equal(w.Date) {
+			t.Errorf("%s: date %v, want %v", w.Description, e.Date, w.Date)
+		}
+	}
+}
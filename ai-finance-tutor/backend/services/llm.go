@@ -128,11 +128,24 @@ func (s *LLMService) GeneratePersona(expenses []models.Expense, style string) (m
 	total := 0.0
 	catMap := make(map[string]float64)
 	for _, e := range expenses {
-		total += e.Amount
-		catMap[e.Category] += e.Amount
+		if e.IsCredit() && !e.IsRefund() || e.IsTransfer() {
+			continue
+		}
+		total += signedSpend(e)
+		catMap[e.Category] += signedSpend(e)
 	}
 	// Simplified context for prompt
 	context := fmt.Sprintf("Total Spent: %.2f. Breakown: %v", total, catMap)
+	pattern := spendingPatternOf(expenses)
+	if pattern.timedSpend > 0 {
+		context += fmt.Sprintf(". Late night (11 PM-4 AM) spend: %.0f%% of timed spend over %d transactions", pattern.lateNight/pattern.timedSpend*100, pattern.lateNightCount)
+	}
+	if pattern.weekendDays > 0 && pattern.weekdayDays > 0 {
+		context += fmt.Sprintf(". Per day: weekends %.2f, weekdays %.2f", pattern.weekend/float64(pattern.weekendDays), pattern.weekday/float64(pattern.weekdayDays))
+	}
+	if day, avg := pattern.peakWeekday(); avg > 0 {
+		context += fmt.Sprintf(". Priciest day: %s", day)
+	}
 
 	prompt := fmt.Sprintf(`SYSTEM: You are a creative writer for a 'Spotify Wrapped' style finance app.
 TASK: Analyze the spending summary below and assign the user a hilarious 'Financial Archetype'.
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+// Payment rails recognised in bank narrations and stored in Expense.Rail.
+const (
+	RailUPI  = "UPI"
+	RailPOS  = "POS"
+	RailNEFT = "NEFT"
+	RailRTGS = "RTGS"
+	RailIMPS = "IMPS"
+	RailATM  = "ATM"
+	RailNACH = "NACH"
+)
+
+// railPrefixes recognise the rail from the start of a narration, as HDFC, ICICI,
+// SBI, Axis and Kotak write it: "UPI/...", "UPI-...", "POS 4321XXXX ...",
+// "MMT/IMPS/...", "NEFT CR-...", "NWD-..." (ATM withdrawal) or "ACH D- ...".
+var railPrefixes = []struct {
+	rail    string
+	pattern *regexp.Regexp
+}{
+	{RailUPI, regexp.MustCompile(`(?i)^(?:by transfer[- ]|to transfer[- ])?upi\b`)},
+	{RailIMPS, regexp.MustCompile(`(?i)^(?:mmt[/ -])?imps\b`)},
+	{RailNEFT, regexp.MustCompile(`(?i)^neft\b`)},
+	{RailRTGS, regexp.MustCompile(`(?i)^rtgs\b`)},
+	{RailATM, regexp.MustCompile(`(?i)^(?:atm|nwd|atw|eaw|cash wdl)\b`)},
+	{RailPOS, regexp.MustCompile(`(?i)^(?:pos|pcd|ecom|vps|ips)\b`)},
+	{RailNACH, regexp.MustCompile(`(?i)^(?:ach|nach)\b`)},
+}
+
+var (
+	narrationVPA  = regexp.MustCompile(`(?i)^[a-z0-9._\-]+@[a-z][a-z0-9]+$`)
+	narrationIFSC = regexp.MustCompile(`^[A-Za-z]{4}0[A-Za-z0-9]{6}$`)
+	// Bank references: UPI RRNs are 12 digits, NEFT/RTGS UTRs mix letters and
+	// digits, IMPS references are digits.
+	narrationRef  = regexp.MustCompile(`^(?:\d{6,}|[A-Za-z]{4}[A-Za-z0-9]*\d{6,}[A-Za-z0-9]*)$`)
+	narrationCard = regexp.MustCompile(`(?i)^(?:\d{4,6}[x*]{2,}\d{0,4}|[x*]{2,}\d{2,4})$`)
+)
+
+// narrationNoise are tokens in a narration that describe the transfer rather
+// than the other party.
+var narrationNoise = map[string]bool{
+	"upi": true, "pos": true, "neft": true, "rtgs": true, "imps": true, "mmt": true,
+	"ach": true, "nach": true, "ecom": true, "pcd": true, "vps": true, "ips": true,
+	"dr": true, "cr": true, "d": true, "c": true, "p2a": true, "p2m": true, "p2p": true,
+	"by": true, "to": true, "transfer": true, "payment": true, "pay": true, "txn": true,
+	"ref": true, "inb": true, "mob": true, "collect": true, "sent": true, "received": true,
+	"atm": true, "nwd": true, "atw": true, "eaw": true, "wdl": true, "cash": true,
+}
+
+// narrationPlaces are city names and codes card terminals append to the
+// merchant name.
+var narrationPlaces = map[string]bool{
+	"in": true, "ind": true, "india": true, "blr": true, "bangalore": true, "bengaluru": true,
+	"mum": true, "bom": true, "mumbai": true, "del": true, "delhi": true,
+	"hyd": true, "hyderabad": true, "maa": true, "chennai": true, "pune": true, "pnq": true,
+	"kol": true, "ccu": true, "kolkata": true, "gurgaon": true, "gurugram": true, "noida": true,
+	"ahmedabad": true, "jaipur": true, "kochi": true, "thane": true,
+}
+
+// legalSuffixes are dropped from the end of a merchant name.
+var legalSuffixes = []string{
+	"private limited", "pvt ltd", "pvt. ltd.", "pvt. ltd", "private ltd", "limited", "ltd",
+	"pvt", "llp", "inc", "corp", "co",
+}
+
+// merchantAliases maps words found in a narration or VPA to the name people
+// know the merchant by, including the legal names that show up on statements.
+// The first match wins.
+var merchantAliases = []struct{ match, name string }{
+	{"bundl technologies", "Swiggy"}, {"swiggy", "Swiggy"},
+	{"zomato", "Zomato"}, {"blinkit", "Blinkit"}, {"zepto", "Zepto"},
+	{"ani technologies", "Ola"}, {"olacabs", "Ola"}, {"uber", "Uber"}, {"rapido", "Rapido"},
+	{"amazon pay", "Amazon"}, {"amzn", "Amazon"}, {"amazon", "Amazon"},
+	{"flipkart", "Flipkart"}, {"myntra", "Myntra"}, {"ajio", "Ajio"}, {"nykaa", "Nykaa"},
+	{"avenue supermarts", "DMart"}, {"dmart", "DMart"},
+	{"supermarket grocery supplies", "BigBasket"}, {"bigbasket", "BigBasket"},
+	{"netflix", "Netflix"}, {"spotify", "Spotify"}, {"youtube", "YouTube"},
+	{"novi digital", "Hotstar"}, {"hotstar", "Hotstar"},
+	{"starbucks", "Starbucks"}, {"mcdonald", "McDonald's"}, {"domino", "Domino's"},
+	{"one97", "Paytm"}, {"paytm", "Paytm"}, {"phonepe", "PhonePe"},
+	{"irctc", "IRCTC"}, {"makemytrip", "MakeMyTrip"},
+	{"bharti airtel", "Airtel"}, {"airtel", "Airtel"}, {"reliance jio", "Jio"}, {"jio", "Jio"},
+}
+
+type MerchantService struct{}
+
+func NewMerchantService() *MerchantService {
+	return &MerchantService{}
+}
+
+// Narration is what Parse reads out of a bank narration.
+type Narration struct {
+	Merchant  string
+	Rail      string
+	VPA       string
+	Reference string
+}
+
+// Parse splits a raw narration such as
+// "UPI/412345678901/ZOMATO LTD/zomato.payu@hdfcbank/Payment" or
+// "POS 4321XXXX STARBUCKS BLR" into the merchant, the payment rail, the UPI
+// address and the bank reference. Descriptions that follow no rail's layout
+// keep their text, minus references and masked card numbers, as the merchant.
+func (s *MerchantService) Parse(description string) Narration {
+	var n Narration
+	text := strings.TrimSpace(description)
+	for _, r := range railPrefixes {
+		if r.pattern.MatchString(text) {
+			n.Rail = r.rail
+			break
+		}
+	}
+
+	if n.Rail == "" {
+		if narrationVPA.MatchString(text) {
+			n.VPA = strings.ToLower(text)
+			n.Merchant = vpaName(n.VPA)
+			if n.Merchant == "" {
+				n.Merchant = n.VPA
+				return n
+			}
+		} else {
+			n.Merchant = cleanMerchantWords(text, "")
+		}
+		if alias := merchantAlias(n.Merchant); alias != "" {
+			n.Merchant = alias
+		} else {
+			n.Merchant = displayName(n.Merchant)
+		}
+		return n
+	}
+
+	// Rail narrations are fields separated by "/", "-" or ":"; card and ATM
+	// narrations separate them with spaces. VPAs keep their dots and dashes.
+	var tokens []string
+	for _, field := range strings.FieldsFunc(text, func(r rune) bool { return r == '/' || r == ':' || r == '|' }) {
+		if narrationVPA.MatchString(strings.TrimSpace(field)) {
+			tokens = append(tokens, strings.TrimSpace(field))
+			continue
+		}
+		for _, part := range strings.Split(field, "-") {
+			if part = strings.TrimSpace(part); part != "" {
+				tokens = append(tokens, part)
+			}
+		}
+	}
+
+	var names []string
+	for _, token := range tokens {
+		switch {
+		case narrationVPA.MatchString(token):
+			if n.VPA == "" {
+				n.VPA = strings.ToLower(token)
+			}
+		case narrationIFSC.MatchString(token):
+		case narrationRef.MatchString(token):
+			if n.Reference == "" {
+				n.Reference = token
+			}
+		default:
+			if name := cleanMerchantWords(token, n.Rail); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	switch {
+	case n.Rail == RailATM:
+		n.Merchant = "ATM Withdrawal"
+	case len(names) > 0:
+		// The other party comes first; remarks such as "Payment" follow it.
+		n.Merchant = names[0]
+	case n.VPA != "":
+		n.Merchant = firstNonEmpty(vpaName(n.VPA), n.VPA)
+	}
+	// Only the part of a VPA before the @ names the payee; the handle names
+	// the app, as in "9876543210@paytm".
+	local, _, _ := strings.Cut(n.VPA, "@")
+	if n.Merchant == n.VPA && n.VPA != "" {
+		return n
+	}
+	if alias := merchantAlias(n.Merchant + " " + local); alias != "" {
+		n.Merchant = alias
+	} else {
+		n.Merchant = displayName(n.Merchant)
+	}
+	return n
+}
+
+// NormalizeExpenses fills in Merchant, Rail, VPA and Reference from each
+// description. Fields the importer already set are kept.
+func (s *MerchantService) NormalizeExpenses(expenses []models.Expense) []models.Expense {
+	for i := range expenses {
+		e := &expenses[i]
+		n := s.Parse(e.Description)
+		e.Merchant = firstNonEmpty(e.Merchant, n.Merchant)
+		e.Rail = firstNonEmpty(e.Rail, n.Rail)
+		e.VPA = firstNonEmpty(e.VPA, n.VPA)
+		e.Reference = firstNonEmpty(e.Reference, n.Reference)
+	}
+	return expenses
+}
+
+// cleanMerchantWords drops masked card numbers and long numbers from one
+// narration field and, for rail narrations, the rail keywords too. Card
+// payments also lose the trailing city.
+func cleanMerchantWords(field, rail string) string {
+	words := strings.Fields(field)
+	var kept []string
+	for _, w := range words {
+		lower := strings.ToLower(strings.Trim(w, ".,*#"))
+		if lower == "" || rail != "" && narrationNoise[lower] || narrationCard.MatchString(w) || narrationRef.MatchString(w) {
+			continue
+		}
+		kept = append(kept, w)
+	}
+	if rail == RailPOS {
+		for len(kept) > 1 && narrationPlaces[strings.ToLower(kept[len(kept)-1])] {
+			kept = kept[:len(kept)-1]
+		}
+	}
+	name := strings.Join(kept, " ")
+	if !strings.ContainsFunc(name, unicode.IsLetter) {
+		return ""
+	}
+	return name
+}
+
+// vpaName guesses a name from the part of a UPI address before the @, unless
+// it is just a phone number.
+func vpaName(vpa string) string {
+	local, _, _ := strings.Cut(vpa, "@")
+	parts := strings.FieldsFunc(local, func(r rune) bool { return r == '.' || r == '-' || r == '_' })
+	if len(parts) == 0 || !strings.ContainsFunc(parts[0], unicode.IsLetter) {
+		return ""
+	}
+	return parts[0]
+}
+
+func merchantAlias(text string) string {
+	lower := strings.ToLower(text)
+	for _, a := range merchantAliases {
+		if strings.Contains(lower, a.match) {
+			return a.name
+		}
+	}
+	return ""
+}
+
+// displayName drops legal suffixes and turns SHOUTED narrations into title
+// case, leaving names that already mix cases alone.
+func displayName(name string) string {
+	name = strings.Join(strings.Fields(name), " ")
+	for trimmed := true; trimmed; {
+		trimmed = false
+		lower := strings.ToLower(name)
+		for _, suffix := range legalSuffixes {
+			if strings.HasSuffix(lower, " "+suffix) {
+				name = strings.TrimSpace(name[:len(name)-len(suffix)])
+				trimmed = true
+				break
+			}
+		}
+	}
+	if name != strings.ToUpper(name) && name != strings.ToLower(name) {
+		return name
+	}
+	words := strings.Fields(strings.ToLower(name))
+	for i, w := range words {
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		words[i] = string(r)
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+// mt940Line matches the :61: statement line: value date YYMMDD, optional entry
+// date MMDD, debit/credit mark, optional funds code, amount with a decimal
+// comma, transaction type, customer reference and optional //bank reference.
+var mt940Line = regexp.MustCompile(`^(\d{6})(\d{4})?(RD|RC|D|C)([A-Z])?([\d,]+)([NF][A-Z0-9]{3})([^/]*)(?://(\S*))?`)
+
+var mt940Tag = regexp.MustCompile(`^:(\d{2}[A-Z]?):`)
+
+type mt940Txn struct {
+	lineNo int
+	line   string
+	info   []string
+}
+
+// StreamMT940 reads a SWIFT MT940 customer statement and calls fn for every
+// transaction. Each :61: line is paired with the :86: information that follows it.
+func (p *ParserService) StreamMT940(ctx context.Context, r io.Reader, fn ExpenseFunc) (*models.ParseReport, error) {
+	rep := newReporter(FormatMT940)
+	scanner := bufio.NewScanner(r)
+	var txn *mt940Txn
+	var tag string
+	var account string  // :25: account identification
+	var currency string // from the :60F: opening balance
+	lineNo := 0
+
+	flush := func() error {
+		if txn == nil {
+			return nil
+		}
+		current := *txn
+		txn = nil
+		expense, err := mt940Expense(current)
+		if err != nil {
+			rep.skip(current.lineNo, err)
+			return nil
+		}
+		rep.imported(mt940DateLayout)
+		expense.Account = account
+		expense.Currency = currency
+		return fn(expense)
+	}
+
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		lineNo++
+
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if m := mt940Tag.FindStringSubmatch(line); m != nil {
+			tag = m[1]
+			value := line[len(m[0]):]
+			switch {
+			case tag == "61":
+				if err := flush(); err != nil {
+					return nil, err
+				}
+				txn = &mt940Txn{lineNo: lineNo, line: value}
+			case tag == "86" && txn != nil:
+				txn.info = append(txn.info, value)
+			case tag == "25":
+				if err := flush(); err != nil {
+					return nil, err
+				}
+				account = mt940Account(value)
+			case tag == "60F" || tag == "60M":
+				// D/C mark, YYMMDD date, then the ISO currency: "C260101INR1234,56".
+				if err := flush(); err != nil {
+					return nil, err
+				}
+				if len(value) >= 10 && currencyCode.MatchString(value[7:10]) {
+					currency = strings.ToUpper(value[7:10])
+				}
+			default:
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		// Continuation lines belong to the last tag we saw.
+		if txn != nil && tag == "86" {
+			txn.info = append(txn.info, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return rep.finish(), nil
+}
+
+// mt940Account labels a :25: value, which is an account number optionally
+// preceded by the bank code, e.g. "37040044/0532013000".
+func mt940Account(value string) string {
+	bank, number, ok := strings.Cut(strings.TrimSpace(value), "/")
+	if !ok {
+		return accountLabel("", bank)
+	}
+	return accountLabel(bank, number)
+}
+
+// mt940DateLayout is the YYMMDD value date of a :61: line.
+const mt940DateLayout = "060102"
+
+func mt940Expense(txn mt940Txn) (models.Expense, error) {
+	m := mt940Line.FindStringSubmatch(txn.line)
+	if m == nil {
+		return models.Expense{}, skipRow(SkipNotTransaction, "unrecognised :61: statement line %q", txn.line)
+	}
+
+	amt, err := parseAmount(strings.Replace(m[5], ",", ".", 1))
+	if err != nil {
+		return models.Expense{}, skipRow(SkipInvalidAmount, "unrecognised amount %q", m[5])
+	}
+	amount := amt.Value
+	if amount == 0 {
+		return models.Expense{}, skipRowWarning(SkipZeroAmount, "%s entry of zero", m[3])
+	}
+	// Reversals flip the direction of the original entry.
+	kind := models.KindCredit
+	if mark := m[3]; mark == "D" || mark == "RC" {
+		kind = models.KindDebit
+	}
+	kind = amt.kind(kind)
+
+	valueDate, err := time.Parse(mt940DateLayout, m[1])
+	if err != nil {
+		return models.Expense{}, skipRow(SkipInvalidDate, "unrecognised value date %q", m[1])
+	}
+	bookingDate := valueDate
+	if m[2] != "" {
+		if t, err := time.Parse("0102", m[2]); err == nil {
+			bookingDate = time.Date(valueDate.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+			// Booked in December, valued in January (or the other way round).
+			if diff := bookingDate.Sub(valueDate); diff > 180*24*time.Hour {
+				bookingDate = bookingDate.AddDate(-1, 0, 0)
+			} else if diff < -180*24*time.Hour {
+				bookingDate = bookingDate.AddDate(1, 0, 0)
+			}
+		}
+	}
+
+	counterparty, remittance := parseMT940Info(txn.info)
+	if remittance == "" {
+		remittance = strings.TrimSpace(m[7])
+		if strings.EqualFold(remittance, "NONREF") {
+			remittance = ""
+		}
+	}
+
+	return models.Expense{
+		Date:          bookingDate,
+		Description:   foldDescription(counterparty, remittance, bookingDate.Format(models.DateLayout), valueDate.Format(models.DateLayout)),
+		Amount:        amount,
+		Kind:          kind,
+		TransactionID: m[8],
+	}, nil
+}
+
+// parseMT940Info splits :86: information into counterparty and remittance text.
+// It understands the German ?NN subfield layout and the /KEY/value layout used by
+// Dutch and many Asian banks; anything else is treated as free remittance text.
+func parseMT940Info(lines []string) (string, string) {
+	if len(lines) == 0 {
+		return "", ""
+	}
+	joined := strings.Join(lines, "")
+
+	if strings.Contains(joined, "?2") || strings.Contains(joined, "?3") {
+		var name, remittance []string
+		for _, field := range strings.Split(joined, "?")[1:] {
+			if len(field) < 2 {
+				continue
+			}
+			code, value := field[:2], strings.TrimSpace(field[2:])
+			switch {
+			case code == "32" || code == "33":
+				name = append(name, value)
+			case code >= "20" && code <= "29", code >= "60" && code <= "63":
+				remittance = append(remittance, value)
+			}
+		}
+		return strings.Join(name, ""), strings.Join(remittance, "")
+	}
+
+	if strings.HasPrefix(joined, "/") {
+		var name, remittance string
+		tokens := strings.Split(joined, "/")
+		for i := 0; i < len(tokens); i++ {
+			switch tokens[i] {
+			case "NAME":
+				if name == "" && i+1 < len(tokens) {
+					name = tokens[i+1]
+				}
+			case "REMI":
+				// /REMI/USTD//text/ wraps the text in a format marker.
+				j := i + 1
+				for j < len(tokens) && (tokens[j] == "USTD" || tokens[j] == "STRD" || tokens[j] == "") {
+					j++
+				}
+				if j < len(tokens) {
+					remittance = tokens[j]
+				}
+			}
+		}
+		if name != "" || remittance != "" {
+			return strings.TrimSpace(name), strings.TrimSpace(remittance)
+		}
+	}
+
+	return "", strings.TrimSpace(strings.Join(lines, " "))
+}
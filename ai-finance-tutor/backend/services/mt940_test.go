@@ -0,0 +1,54 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+func TestMT940Direction(t *testing.T) {
+	tests := []struct {
+		line   string
+		amount float64
+		kind   string
+	}{
+		{"2601050105D450,00NMSCNONREF", 450, models.KindDebit},
+		{"2601050105C1200,50NTRFNONREF//INV42", 1200.5, models.KindCredit},
+		{"260105RC450,00NMSCNONREF", 450, models.KindDebit},     // Reversed credit
+		{"260105RD99,NMSCNONREF", 99, models.KindCredit},        // Reversed debit
+		{"2601050105DR450,00NMSCNONREF", 450, models.KindDebit}, // With funds code
+	}
+	for _, tt := range tests {
+		e, err := mt940Expense(mt940Txn{line: tt.line})
+		if err != nil {
+			t.Errorf("mt940Expense(%q): %v", tt.line, err)
+			continue
+		}
+		if e.Amount != tt.amount || e.Kind != tt.kind {
+			t.Errorf("mt940Expense(%q) = %.2f %s, want %.2f %s", tt.line, e.Amount, e.Kind, tt.amount, tt.kind)
+		}
+	}
+}
+
+func TestAmountKind(t *testing.T) {
+	tests := []struct {
+		text, given, want string
+	}{
+		{"450.00", models.KindDebit, models.KindDebit},
+		{"450.00", models.KindCredit, models.KindCredit},
+		{"450.00 Cr", models.KindDebit, models.KindCredit},
+		{"450.00 CR", models.KindDebit, models.KindCredit},
+		{"450.00 Dr", models.KindCredit, models.KindDebit},
+		{"Dr 450.00", models.KindCredit, models.KindDebit},
+	}
+	for _, tt := range tests {
+		amt, err := parseAmount(tt.text)
+		if err != nil {
+			t.Errorf("parseAmount(%q): %v", tt.text, err)
+			continue
+		}
+		if got := amt.kind(tt.given); got != tt.want {
+			t.Errorf("parseAmount(%q).kind(%s) = %s, want %s", tt.text, tt.given, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+// StreamOFX reads an OFX or QFX statement (SGML 1.x or XML 2.x) and calls fn for
+// every transaction. Leaf elements in SGML files are not closed, so both
+// dialects are read with the same tolerant tag scanner.
+func (p *ParserService) StreamOFX(ctx context.Context, r io.Reader, fn ExpenseFunc) (*models.ParseReport, error) {
+	rep := newReporter(FormatOFX)
+	scanner := &ofxScanner{br: bufio.NewReader(r), line: 1}
+	var txn map[string]string
+	var txnLine int
+	var org, account string // FI ORG and ACCTID of the statement being read
+	var currency string     // CURDEF, the statement's default currency
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		tag, text, err := scanner.next()
+		if err == io.EOF {
+			return rep.finish(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch tag {
+		case "STMTTRN":
+			txn = make(map[string]string)
+			txnLine = scanner.tagLine
+		case "/STMTTRN":
+			expense, err := ofxExpense(txn)
+			txn = nil
+			if err != nil {
+				rep.skip(txnLine, err)
+				continue
+			}
+			rep.imported(ofxDateLayout)
+			if account != "" {
+				expense.Account = accountLabel(org, account)
+			}
+			if expense.Currency == "" {
+				expense.Currency = strings.ToUpper(currency)
+			}
+			if err := fn(expense); err != nil {
+				return nil, err
+			}
+		case "ORG":
+			org = text
+		case "ACCTID":
+			account = text
+		case "CURDEF":
+			currency = text
+		case "CURRENCY":
+			// The amount is in CURSYM rather than CURDEF. ORIGCURRENCY, by
+			// contrast, names what the amount was converted from.
+			if txn != nil {
+				txn[tag] = ""
+			}
+		default:
+			if txn != nil && text != "" && !strings.HasPrefix(tag, "/") {
+				// PAYEE blocks repeat NAME; keep the first one we see.
+				if _, seen := txn[tag]; !seen {
+					txn[tag] = text
+				}
+			}
+		}
+	}
+}
+
+// ofxScanner walks OFX elements while keeping track of the current line.
+type ofxScanner struct {
+	br      *bufio.Reader
+	line    int
+	tagLine int // line of the tag last returned by next
+}
+
+// next returns the next tag name (upper-cased, "/" prefixed for closing tags)
+// and the text that follows it up to the next tag.
+func (s *ofxScanner) next() (string, string, error) {
+	skipped, err := s.br.ReadString('<')
+	s.line += strings.Count(skipped, "\n")
+	if err != nil {
+		return "", "", err
+	}
+	s.tagLine = s.line
+	tag, err := s.br.ReadString('>')
+	s.line += strings.Count(tag, "\n")
+	if err != nil {
+		return "", "", err
+	}
+	tag = strings.ToUpper(strings.TrimSpace(strings.TrimSuffix(tag, ">")))
+
+	text, err := s.br.ReadString('<')
+	if err == nil {
+		s.br.UnreadByte()
+		text = text[:len(text)-1]
+	} else if err != io.EOF {
+		return "", "", err
+	}
+	s.line += strings.Count(text, "\n")
+	return tag, unescapeOFX(strings.TrimSpace(text)), nil
+}
+
+func unescapeOFX(s string) string {
+	return strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'").Replace(s)
+}
+
+// ofxDateLayout is the date part of OFX DTPOSTED values.
+const ofxDateLayout = "20060102"
+
+// ofxExpense maps a STMTTRN block. OFX amounts are signed from the account's
+// point of view, so spends are negative TRNAMT values and credits positive.
+// Currency is only set when the block has its own CURRENCY aggregate.
+func ofxExpense(txn map[string]string) (models.Expense, error) {
+	amt, err := parseAmount(txn["TRNAMT"])
+	if err != nil {
+		return models.Expense{}, skipRow(SkipInvalidAmount, "unrecognised TRNAMT %q", txn["TRNAMT"])
+	}
+	if amt.Value == 0 {
+		return models.Expense{}, skipRowWarning(SkipZeroAmount, "%s of zero", strings.ToLower(txn["TRNTYPE"]))
+	}
+	amount, kind := direction(-amt.Value)
+	kind = amt.kind(kind)
+
+	date, err := parseOFXDate(txn["DTPOSTED"])
+	if err != nil {
+		return models.Expense{}, skipRow(SkipInvalidDate, "unrecognised DTPOSTED %q", txn["DTPOSTED"])
+	}
+
+	description := txn["NAME"]
+	if memo := txn["MEMO"]; memo != "" && !strings.EqualFold(memo, description) {
+		description = strings.TrimSpace(description + " " + memo)
+	}
+
+	var currency string
+	if _, ok := txn["CURRENCY"]; ok {
+		currency = strings.ToUpper(txn["CURSYM"])
+	}
+
+	return models.Expense{
+		Date:          date,
+		Description:   description,
+		Amount:        amount,
+		Kind:          kind,
+		TransactionID: txn["FITID"],
+		Currency:      currency,
+	}, nil
+}
+
+// parseOFXDate handles YYYYMMDD[HHMMSS[.XXX]][[gmt offset:tz name]], keeping
+// the time as the bank wrote it and ignoring the offset.
+func parseOFXDate(s string) (time.Time, error) {
+	if len(s) >= 14 {
+		if t, err := time.Parse(ofxDateLayout+"150405", s[:14]); err == nil {
+			return t, nil
+		}
+	}
+	if len(s) > 8 {
+		s = s[:8]
+	}
+	return time.Parse(ofxDateLayout, s)
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+// Values of Expense.CategoryRule for categories the user picked by hand.
+const (
+	RuleManual   = "manual"   // Chosen for that one transaction
+	RuleMerchant = "merchant" // From a merchant override
+)
+
+// MerchantOverrides are one user's merchant overrides, keyed by merchantKey.
+type MerchantOverrides map[string]models.MerchantOverride
+
+// merchantKey identifies who a transaction paid, for overrides: the normalized
+// merchant, or the description when none was found, ignoring case and spacing.
+func merchantKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// MerchantOf is the name overrides know a transaction's merchant by.
+func MerchantOf(e models.Expense) string {
+	return strings.Join(strings.Fields(firstNonEmpty(e.Merchant, e.Description)), " ")
+}
+
+// Set files every spend at the merchant of e under category.
+func (o MerchantOverrides) Set(e models.Expense, category string) models.MerchantOverride {
+	override := models.MerchantOverride{Merchant: MerchantOf(e), Category: category}
+	o[merchantKey(override.Merchant)] = override
+	return override
+}
+
+// Delete removes the override for a merchant and reports whether it existed.
+func (o MerchantOverrides) Delete(merchant string) bool {
+	key := merchantKey(merchant)
+	_, ok := o[key]
+	delete(o, key)
+	return ok
+}
+
+// Lookup finds the override for the merchant of e.
+func (o MerchantOverrides) Lookup(e models.Expense) (models.MerchantOverride, bool) {
+	override, ok := o[merchantKey(MerchantOf(e))]
+	return override, ok
+}
+
+// List returns the overrides sorted by merchant.
+func (o MerchantOverrides) List() []models.MerchantOverride {
+	list := make([]models.MerchantOverride, 0, len(o))
+	for _, override := range o {
+		list = append(list, override)
+	}
+	slices.SortFunc(list, func(a, b models.MerchantOverride) int {
+		return strings.Compare(merchantKey(a.Merchant), merchantKey(b.Merchant))
+	})
+	return list
+}
+
+// SameMerchant reports whether two transactions were paid to the same merchant.
+func SameMerchant(a, b models.Expense) bool {
+	return merchantKey(MerchantOf(a)) == merchantKey(MerchantOf(b))
+}
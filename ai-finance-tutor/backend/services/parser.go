@@ -1,24 +1,84 @@
 package services
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/csv"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"slices"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/siddhartharajbongshi/spendsense-backend/models"
 )
 
-type ParserService struct{}
+type ParserService struct {
+	templates *templateStore
+}
 
 func NewParserService() *ParserService {
-	return &ParserService{}
+	return &ParserService{templates: newTemplateStore()}
+}
+
+// ExpenseFunc is called for every transaction, debit or credit, as it is parsed.
+// Returning an error stops parsing and the error is passed back to the caller.
+type ExpenseFunc func(models.Expense) error
+
+// ParseOptions tunes how a statement is read. The zero value auto-detects everything.
+type ParseOptions struct {
+	// Format forces the input format (FormatCSV, FormatOFX, FormatQIF, ...). Empty means
+	// sniff it from the content.
+	Format string
+	// Profile forces a statement profile by ID ("hdfc", "sbi", "generic", ...)
+	// instead of detecting it from the header and preamble.
+	Profile string
+	// Sheet selects the worksheet of an XLSX upload by name or 1-based index.
+	Sheet string
+	// Locale decides how amounts are read ("en-IN", "de-DE", ...). Empty means
+	// guess the decimal mark per value. "en-US" also prefers MM/DD dates.
+	Locale string
+	// DateFormat overrides date inference, as a Go layout or in DD/MM/YYYY form.
+	DateFormat string
+	// Encoding overrides text encoding detection ("utf-8", "utf-16le",
+	// "windows-1252", ...).
+	Encoding string
+	// Delimiter overrides CSV delimiter detection: ",", ";", "tab" or "|".
+	Delimiter string
+	// Mapping names the columns of a CSV or XLSX layout no profile knows. It
+	// takes precedence over Template and Profile.
+	Mapping *models.ColumnMapping
+	// Template forces a saved mapping template by name. Without it, a template
+	// whose header signature matches is applied automatically.
+	Template string
+}
+
+// rowReader is the minimal row source the tabular importers are built on.
+type rowReader interface {
+	Read() ([]string, error)
+	// Line is the file line (or sheet row) of the record last returned by Read.
+	Line() int
+}
+
+// csvRows adapts *csv.Reader to rowReader.
+type csvRows struct {
+	*csv.Reader
+	quoted bool // Sniffed: fields are wrapped in double quotes
 }
 
+func (c csvRows) Line() int {
+	line, _ := c.FieldPos(0)
+	return line
+}
+
+// maxPreambleRows bounds how far we look for the header row of a bank export.
+const maxPreambleRows = 30
+
 func (p *ParserService) ParseCSV(filePath string) ([]models.Expense, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -26,35 +86,206 @@ func (p *ParserService) ParseCSV(filePath string) ([]models.Expense, error) {
 	}
 	defer file.Close()
 
-	reader := csv.NewReader(file)
+	expenses, _, err := p.ParseReader(context.Background(), file, ParseOptions{})
+	return expenses, err
+}
+
+// ParseReader parses a statement in any supported format from r and returns the
+// expenses sorted by date, along with a report of the rows that were skipped.
+func (p *ParserService) ParseReader(ctx context.Context, r io.Reader, opts ParseOptions) ([]models.Expense, *models.ParseReport, error) {
+	var expenses []models.Expense
+	report, err := p.Stream(ctx, r, opts, func(e models.Expense) error {
+		expenses = append(expenses, e)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sortExpenses(expenses)
+	return expenses, report, nil
+}
 
-	// Read header
-	header, err := reader.Read()
+// Stream detects the format of r (unless opts.Format is set) and hands it to the
+// matching streaming parser. Text formats are decoded to UTF-8 first.
+func (p *ParserService) Stream(ctx context.Context, r io.Reader, opts ParseOptions, fn ExpenseFunc) (*models.ParseReport, error) {
+	in, err := openInput(r, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	// Validate columns
-	required := map[string]bool{"date": false, "amount": false, "description": false}
-	colMap := make(map[string]int)
+	// Note every account the transactions are tagged with, in order of appearance.
+	var accounts []string
+	seen := make(map[string]bool)
+	next := fn
+	fn = func(e models.Expense) error {
+		if e.Account != "" && !seen[e.Account] {
+			seen[e.Account] = true
+			accounts = append(accounts, e.Account)
+		}
+		return next(e)
+	}
 
-	for i, col := range header {
-		lowerCol := strings.ToLower(strings.TrimSpace(col))
-		if _, exists := required[lowerCol]; exists {
-			required[lowerCol] = true
-			colMap[lowerCol] = i
+	var report *models.ParseReport
+	if in.format == FormatXLSX {
+		data, err := io.ReadAll(in.br)
+		if err != nil {
+			return nil, err
+		}
+		report, err = p.StreamXLSX(ctx, bytes.NewReader(data), int64(len(data)), opts, fn)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		report, err = p.streamText(ctx, in.br, in.format, opts, fn)
+		if err != nil {
+			return nil, err
 		}
+		report.Encoding = in.text.Encoding()
+	}
+	report.Accounts = accounts
+	return report, nil
+}
+
+// input is an upload whose format is settled and which, unless it is XLSX, has
+// been decoded to UTF-8.
+type input struct {
+	format string
+	br     *bufio.Reader
+	text   *textReader // nil for XLSX
+}
+
+func openInput(r io.Reader, opts ParseOptions) (*input, error) {
+	br := bufio.NewReader(r)
+	format := strings.ToLower(opts.Format)
+	if head, _ := br.Peek(sniffLen); format == "" && sniffFormat(head) == FormatXLSX {
+		format = FormatXLSX
+	}
+	if format == FormatXLSX {
+		return &input{format: format, br: br}, nil
+	}
+
+	text, err := newTextReader(br, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	br = bufio.NewReader(text)
+	if format == "" {
+		head, _ := br.Peek(sniffLen)
+		format = sniffFormat(head)
+	}
+	return &input{format: format, br: br, text: text}, nil
+}
+
+func (p *ParserService) streamText(ctx context.Context, br *bufio.Reader, format string, opts ParseOptions, fn ExpenseFunc) (*models.ParseReport, error) {
+	switch format {
+	case FormatCSV:
+		return p.StreamCSV(ctx, br, opts, fn)
+	case FormatOFX, "qfx":
+		return p.StreamOFX(ctx, br, fn)
+	case FormatQIF:
+		return p.StreamQIF(ctx, br, opts, fn)
+	case FormatCamt053:
+		return p.StreamCamt053(ctx, br, fn)
+	case FormatMT940:
+		return p.StreamMT940(ctx, br, fn)
+	case FormatSMS:
+		return p.StreamSMSBackup(ctx, br, fn)
+	case FormatJSON, FormatNDJSON, "jsonl":
+		return p.StreamJSON(ctx, br, opts, fn)
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// StreamCSV parses a CSV statement from r and calls fn for each expense in file
+// order, without buffering the whole input. Parsing stops when ctx is cancelled.
+// The delimiter is sniffed from the first lines unless opts.Delimiter is set.
+func (p *ParserService) StreamCSV(ctx context.Context, r io.Reader, opts ParseOptions, fn ExpenseFunc) (*models.ParseReport, error) {
+	rows, err := newCSVRows(r, opts)
+	if err != nil {
+		return nil, err
 	}
+	report, err := p.streamRows(ctx, rows, FormatCSV, opts, fn)
+	if err != nil {
+		return nil, err
+	}
+	report.Delimiter = delimiterName(rows.Comma)
+	report.Quoted = rows.quoted
+	return report, nil
+}
 
-	for col, found := range required {
-		if !found {
-			return nil, fmt.Errorf("missing required column: %s", col)
+// newCSVRows sets up a tolerant csv.Reader using the sniffed delimiter, or
+// opts.Delimiter when set.
+func newCSVRows(r io.Reader, opts ParseOptions) (csvRows, error) {
+	br := bufio.NewReaderSize(r, delimiterSniffLen)
+	head, _ := br.Peek(delimiterSniffLen)
+	delimiter, quoted := sniffDelimiter(head)
+	switch strings.ToLower(opts.Delimiter) {
+	case "":
+	case "tab", "\\t", "\t":
+		delimiter = '\t'
+	default:
+		d, size := utf8.DecodeRuneInString(opts.Delimiter)
+		if size != len(opts.Delimiter) || d == '"' || d == '\r' || d == '\n' {
+			return csvRows{}, fmt.Errorf("unsupported delimiter: %q", opts.Delimiter)
 		}
+		delimiter = d
 	}
 
-	var expenses []models.Expense
+	reader := csv.NewReader(br)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1 // Preamble and footer rows have their own widths
+	reader.LazyQuotes = true
+	return csvRows{Reader: reader, quoted: quoted}, nil
+}
+
+func (p *ParserService) streamRows(ctx context.Context, reader rowReader, format string, opts ParseOptions, fn ExpenseFunc) (*models.ParseReport, error) {
+	rep := newReporter(format)
+	profile, cols, preamble, err := p.findHeader(reader, opts)
+	if err != nil {
+		return nil, err
+	}
+	account := statementAccount(profile, preamble)
+	rep.report.Profile = profile.ID
+	if profile.ID == templateProfileID {
+		rep.report.Template = profile.Name
+	}
+
+	// Rows are held back only while the file's date layout is still open, e.g.
+	// when every date so far fits both DD/MM and MM/DD.
+	dates := newDateInferrer(profile.DateLayouts, opts.DateFormat, opts.Locale)
+	type pendingRow struct {
+		line   int
+		record []string
+	}
+	var pending []pendingRow
+
+	emit := func(line int, record []string) error {
+		expense, layout, err := profile.expense(record, cols, opts.Locale, dates)
+		if err != nil {
+			rep.skip(line, err)
+			return nil
+		}
+		rep.imported(layout)
+		expense.Account = account
+		return fn(expense)
+	}
+	flush := func() error {
+		for _, row := range pending {
+			if err := emit(row.line, row.record); err != nil {
+				return err
+			}
+		}
+		pending = nil
+		return nil
+	}
 
 	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		record, err := reader.Read()
 		if err == io.EOF {
 			break
@@ -62,70 +293,226 @@ func (p *ParserService) ParseCSV(filePath string) ([]models.Expense, error) {
 		if err != nil {
 			return nil, err
 		}
+		if isBlankRow(record) {
+			continue
+		}
+
+		if !dates.decided() {
+			dates.observe(cell(record, cols.date))
+			pending = append(pending, pendingRow{reader.Line(), record})
+			if dates.decided() {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if err := emit(reader.Line(), record); err != nil {
+			return nil, err
+		}
+	}
+
+	// A bank profile's own layouts are known, not guessed.
+	if !slices.Contains(profile.DateLayouts, dates.layout()) {
+		rep.dateAlternatives(dates)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return rep.finish(), nil
+}
+
+// findHeader skips preamble rows until one of them is recognised as the header
+// of a saved template or a known profile. The preamble text is used to tell
+// banks apart and is returned for the account details it carries.
+func (p *ParserService) findHeader(reader rowReader, opts ParseOptions) (StatementProfile, columnMap, string, error) {
+	var forced *StatementProfile
+	switch {
+	case opts.Mapping != nil:
+		if err := checkMapping(nil, *opts.Mapping); err != nil {
+			return StatementProfile{}, columnMap{}, "", err
+		}
+		profile := mappingProfile(customProfileID, "Custom mapping", *opts.Mapping)
+		forced = &profile
+	case opts.Template != "":
+		t, ok := p.templates.get(opts.Template)
+		if !ok {
+			return StatementProfile{}, columnMap{}, "", fmt.Errorf("unknown mapping template: %s", opts.Template)
+		}
+		profile := templateProfile(t)
+		forced = &profile
+	case opts.Profile != "":
+		profile, ok := profileByID(opts.Profile)
+		if !ok {
+			return StatementProfile{}, columnMap{}, "", fmt.Errorf("unknown statement profile: %s", opts.Profile)
+		}
+		forced = &profile
+	}
 
-		amountStr := record[colMap["amount"]]
-		amount, err := strconv.ParseFloat(amountStr, 64)
+	var preamble strings.Builder
+	var first []string
+	for i := 0; i < maxPreambleRows; i++ {
+		record, err := reader.Read()
+		if err == io.EOF && first != nil {
+			break
+		}
+		if err == io.EOF {
+			return StatementProfile{}, columnMap{}, "", fmt.Errorf("file is empty")
+		}
 		if err != nil {
-			continue // Skip invalid amounts
+			return StatementProfile{}, columnMap{}, "", err
 		}
-		if amount <= 0 {
-			continue // Skip non-expenses or zero values
+		if first == nil && strings.TrimSpace(strings.Join(record, "")) != "" {
+			first = record
 		}
 
-		dateStr := record[colMap["date"]]
-		// Try parsing date to standardize it (optional, but good for validation)
-		parsedDate, err := parseDate(dateStr)
-		if err == nil {
-			dateStr = parsedDate
+		if forced != nil {
+			if cols := forced.match(record); cols.valid() {
+				return *forced, cols, preamble.String(), nil
+			}
 		} else {
-			// If duplicate formats or tricky ones, maybe try more parsers.
-			// For now, keep original or error? Let's keep original if parse fails but log/print?
-			// Actually, let's enforce YYYY-MM-DD for consistency if possible, or just pass through.
-			// The user sample is YYYY-MM-DD.
+			if t, ok := p.templates.match(record); ok {
+				profile := templateProfile(t)
+				if cols := profile.match(record); cols.valid() {
+					return profile, cols, preamble.String(), nil
+				}
+			}
+			if profile, cols, ok := detectProfile(record, preamble.String()); ok {
+				return profile, cols, preamble.String(), nil
+			}
 		}
+		preamble.WriteString(strings.Join(record, " "))
+		preamble.WriteString("\n")
+	}
 
-		expenses = append(expenses, models.Expense{
-			Date:        dateStr,
-			Description: record[colMap["description"]],
-			Amount:      amount,
-		})
+	// Nothing matched: report what the plain three-column layout is missing.
+	want := genericProfile
+	if forced != nil {
+		want = *forced
+	}
+	missing := func(field string, aliases []string) error {
+		if want.ID == customProfileID || want.ID == templateProfileID {
+			return fmt.Errorf("missing required column: %s (%q)", field, strings.Join(aliases, "/"))
+		}
+		return fmt.Errorf("missing required column: %s", field)
+	}
+	cols := want.match(first)
+	switch {
+	case cols.date < 0:
+		return StatementProfile{}, columnMap{}, "", missing("date", want.Date)
+	case cols.amount < 0 && cols.debit < 0 && cols.credit < 0:
+		return StatementProfile{}, columnMap{}, "", missing("amount", slices.Concat(want.Amount, want.Debit, want.Credit))
+	default:
+		return StatementProfile{}, columnMap{}, "", missing("description", want.Description)
 	}
+}
+
+// expense converts one data row using the file's date order and reports the
+// layout that matched. p.amount signs debits positive and credits negative.
+// A time of day comes with the date or from a time column. The currency comes
+// from a currency column, else from a symbol or code on the amount.
+func (p StatementProfile) expense(record []string, cols columnMap, locale string, dates *dateInferrer) (models.Expense, string, error) {
+	rawDate := cell(record, cols.date)
+	date, layout, dateErr := dates.parse(rawDate)
+
+	amount, currency, err := p.amount(record, cols, locale)
+	if err != nil {
+		if dateErr != nil {
+			// Neither a date nor an amount: a footer, subtotal or separator line.
+			return models.Expense{}, "", skipRowWarning(SkipNotTransaction, "row is not a transaction")
+		}
+		return models.Expense{}, "", err
+	}
+	if amount == 0 {
+		return models.Expense{}, "", skipRowWarning(SkipZeroAmount, "amount is zero")
+	}
+	if dateErr != nil {
+		return models.Expense{}, "", skipRow(SkipInvalidDate, "date %q does not match %s", rawDate, describeLayout(layout))
+	}
+	if raw := cell(record, cols.time); raw != "" && date.Equal(calendarDay(date)) {
+		if _, clock, ok := splitClock(raw); ok {
+			date = date.Add(clock)
+		}
+	}
+	if c := cell(record, cols.currency); c != "" {
+		if !currencyCode.MatchString(c) {
+			return models.Expense{}, "", skipRow(SkipInvalidAmount, "unrecognised currency %q", c)
+		}
+		currency = strings.ToUpper(c)
+	}
+	amount, kind := direction(amount)
 
-	// Sort by date
-	sort.Slice(expenses, func(i, j int) bool {
-		return expenses[i].Date < expenses[j].Date
+	return models.Expense{
+		Date:        date,
+		Description: cell(record, cols.description),
+		Amount:      amount,
+		Kind:        kind,
+		Category:    cell(record, cols.category),
+		Currency:    currency,
+	}, layout, nil
+}
+
+// amount returns the row's spend, positive for debits and negative for
+// credits, and the currency its symbol or code names, if any. Explicit Dr/Cr
+// markers on the value or in a Dr/Cr column override the sign.
+func (p StatementProfile) amount(record []string, cols columnMap, locale string) (float64, string, error) {
+	if raw := cell(record, cols.amount); raw != "" {
+		amt, err := parseMoney(raw, locale)
+		if err != nil {
+			return 0, "", skipRow(SkipInvalidAmount, "unrecognised amount %q", raw)
+		}
+		if strings.HasPrefix(strings.ToLower(cell(record, cols.drcr)), "cr") {
+			amt.Credit = true
+		}
+		switch {
+		case amt.Credit:
+			return -math.Abs(amt.Value), amt.Currency, nil
+		case amt.Debit:
+			return math.Abs(amt.Value), amt.Currency, nil
+		}
+		return amt.Value, amt.Currency, nil
+	}
+
+	debit, credit := cell(record, cols.debit), cell(record, cols.credit)
+	if debit != "" {
+		amt, err := parseMoney(debit, locale)
+		if err != nil {
+			return 0, "", skipRow(SkipInvalidAmount, "unrecognised debit amount %q", debit)
+		}
+		if amt.Value != 0 || credit == "" {
+			return math.Abs(amt.Value), amt.Currency, nil
+		}
+	}
+	if credit != "" {
+		amt, err := parseMoney(credit, locale)
+		if err != nil {
+			return 0, "", skipRow(SkipInvalidAmount, "unrecognised credit amount %q", credit)
+		}
+		return -math.Abs(amt.Value), amt.Currency, nil
+	}
+	return 0, "", skipRow(SkipInvalidAmount, "missing amount")
+}
+
+func sortExpenses(expenses []models.Expense) {
+	sort.SliceStable(expenses, func(i, j int) bool {
+		return expenses[i].Date.Before(expenses[j].Date)
 	})
+}
 
-	return expenses, nil
+func isBlankRow(record []string) bool {
+	return strings.TrimSpace(strings.Join(record, "")) == ""
 }
 
-func parseDate(dateStr string) (string, error) {
-	formats := []string{"2006-01-02", "02-01-2006", "1/2/2006", "2006/01/02"}
+// parseDateLayout reads a date, trying the given layouts before the defaults,
+// and reports which layout matched.
+func parseDateLayout(dateStr string, layouts ...string) (time.Time, string, error) {
+	dateStr = strings.TrimSpace(dateStr)
+	formats := append(slices.Clip(layouts), dateLayouts...)
 	for _, format := range formats {
 		t, err := time.Parse(format, dateStr)
 		if err == nil {
-			return t.Format("2006-01-02"), nil
-		}
-	}
-	return "", fmt.Errorf("unknown date format")
-}
-
-func (p *ParserService) GenerateSampleData() []models.Expense {
-	return []models.Expense{
-		{Date: "2026-01-01", Description: "Zomato Order", Amount: 450},
-		{Date: "2026-01-02", Description: "Netflix Subscription", Amount: 199},
-		{Date: "2026-01-03", Description: "Autorickshaw", Amount: 50},
-		{Date: "2026-01-04", Description: "Grocery Shopping", Amount: 1200},
-		{Date: "2026-01-05", Description: "Amazon Prime", Amount: 179},
-		{Date: "2026-01-06", Description: "Swiggy Delivery", Amount: 380},
-		{Date: "2026-01-07", Description: "Spotify Premium", Amount: 119},
-		{Date: "2026-01-08", Description: "Burger King", Amount: 320},
-		{Date: "2026-01-09", Description: "Uber", Amount: 280},
-		{Date: "2026-01-10", Description: "Mobile Recharge", Amount: 399},
-		{Date: "2026-01-11", Description: "DMart Shopping", Amount: 850},
-		{Date: "2026-01-12", Description: "Netflix Payment", Amount: 199},
-		{Date: "2026-01-13", Description: "Coffee - Starbucks", Amount: 250},
-		{Date: "2026-01-14", Description: "Swiggy Order", Amount: 520},
-		{Date: "2026-01-15", Description: "Book Purchase", Amount: 450},
+			return t, format, nil
+		}
 	}
+	return time.Time{}, "", fmt.Errorf("unknown date format")
 }
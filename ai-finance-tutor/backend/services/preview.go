@@ -0,0 +1,148 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+// previewRows is how many rows after the header Preview returns.
+const previewRows = 10
+
+// Preview reads the start of a CSV or XLSX upload and shows its header row, the
+// first rows after it and the mapping an import would use. When no header is
+// recognised, the first non-empty row is offered as the header and Error says
+// what is missing, so the client can ask the user to map the columns.
+func (p *ParserService) Preview(ctx context.Context, r io.Reader, opts ParseOptions) (*models.FilePreview, error) {
+	in, err := openInput(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	preview := &models.FilePreview{Format: in.format, Rows: [][]string{}}
+
+	var reader rowReader
+	switch in.format {
+	case FormatCSV:
+		rows, err := newCSVRows(in.br, opts)
+		if err != nil {
+			return nil, err
+		}
+		preview.Delimiter = delimiterName(rows.Comma)
+		reader = rows
+	case FormatXLSX:
+		data, err := io.ReadAll(in.br)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := openXLSXSheet(bytes.NewReader(data), int64(len(data)), opts.Sheet)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		preview.Sheet = opts.Sheet
+		reader = rows
+	default:
+		return nil, fmt.Errorf("preview is only available for CSV and XLSX files, not %s", in.format)
+	}
+
+	// Buffer everything header detection may look at, plus the rows to show.
+	buffered := &sliceRows{}
+	for len(buffered.records) < maxPreambleRows+previewRows {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		buffered.records = append(buffered.records, record)
+		buffered.lines = append(buffered.lines, reader.Line())
+	}
+	if in.text != nil {
+		preview.Encoding = in.text.Encoding()
+	}
+
+	profile, cols, _, err := p.findHeader(buffered, opts)
+	if err == nil {
+		preview.Profile = profile.ID
+		if profile.ID == templateProfileID {
+			preview.Template = profile.Name
+		}
+	} else {
+		preview.Error = err.Error()
+		// Offer the first of the widest rows as the header: preamble lines
+		// are narrow, and data rows come after the header.
+		widest := 0
+		for i, record := range buffered.records {
+			if n := filledCells(record); n > widest {
+				widest, buffered.next = n, i+1
+			}
+		}
+		if widest == 0 {
+			return nil, fmt.Errorf("file is empty")
+		}
+	}
+
+	header := buffered.records[buffered.next-1]
+	preview.HeaderLine = buffered.lines[buffered.next-1]
+	for _, col := range header {
+		preview.Headers = append(preview.Headers, strings.TrimSpace(col))
+	}
+	preview.Signature = headerSignature(header)
+	if err == nil {
+		mapping := cols.mapping(header)
+		if t, ok := p.templates.get(preview.Template); ok {
+			mapping.DateFormat = t.Mapping.DateFormat
+		}
+		preview.Mapping = &mapping
+	}
+
+	for _, record := range buffered.records[buffered.next:] {
+		if len(preview.Rows) == previewRows {
+			break
+		}
+		if !isBlankRow(record) {
+			preview.Rows = append(preview.Rows, record)
+		}
+	}
+	return preview, nil
+}
+
+// sliceRows replays buffered records as a rowReader.
+type sliceRows struct {
+	records [][]string
+	lines   []int
+	next    int
+}
+
+func (s *sliceRows) Read() ([]string, error) {
+	if s.next >= len(s.records) {
+		return nil, io.EOF
+	}
+	s.next++
+	return s.records[s.next-1], nil
+}
+
+func (s *sliceRows) Line() int {
+	if s.next == 0 {
+		return 0
+	}
+	return s.lines[s.next-1]
+}
+
+func filledCells(record []string) int {
+	n := 0
+	for _, c := range record {
+		if strings.TrimSpace(c) != "" {
+			n++
+		}
+	}
+	return n
+}
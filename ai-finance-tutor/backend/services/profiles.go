@@ -0,0 +1,254 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+// StatementProfile describes the CSV export of one bank: which header names map
+// to which field, which words in the preamble identify it, and the date layouts
+// it uses. Column aliases are matched after normalizeHeader.
+type StatementProfile struct {
+	ID          string
+	Name        string
+	Bank        string   // short name used in account labels, e.g. "HDFC"
+	Markers     []string // lowercase words found in the preamble, e.g. "hdfc bank"
+	Date        []string // in order of preference
+	Description []string
+	Amount      []string // single signed/unsigned amount column
+	Debit       []string // withdrawal column
+	Credit      []string // deposit column
+	DrCr        []string // "Dr"/"Cr" indicator next to Amount
+	Category    []string // category assigned by the bank or another app
+	Currency    []string // ISO code of each row's amount, for multi-currency exports
+	Time        []string // time of day, when kept apart from the date
+	DateLayouts []string
+}
+
+var genericProfile = StatementProfile{
+	ID:          "generic",
+	Name:        "Generic CSV",
+	Date:        []string{"date"},
+	Description: []string{"description"},
+	Amount:      []string{"amount"},
+	Currency:    []string{"currency", "ccy"},
+	Time:        []string{"time", "transaction time", "txn time"},
+}
+
+var bankProfiles = []StatementProfile{
+	{
+		ID:          "hdfc",
+		Name:        "HDFC Bank",
+		Bank:        "HDFC",
+		Markers:     []string{"hdfc bank", "hdfcbank"},
+		Date:        []string{"Date", "Transaction Date", "Value Dt"},
+		Description: []string{"Narration"},
+		Debit:       []string{"Withdrawal Amt.", "Withdrawal Amount"},
+		Credit:      []string{"Deposit Amt.", "Deposit Amount"},
+		DateLayouts: []string{"02/01/06", "02/01/2006"},
+	},
+	{
+		ID:          "icici",
+		Name:        "ICICI Bank",
+		Bank:        "ICICI",
+		Markers:     []string{"icici"},
+		Date:        []string{"Transaction Date", "Txn Date", "Value Date"},
+		Description: []string{"Transaction Remarks", "Remarks", "Particulars"},
+		Debit:       []string{"Withdrawal Amount (INR )", "Withdrawal Amount (INR)", "Withdrawal Amount"},
+		Credit:      []string{"Deposit Amount (INR )", "Deposit Amount (INR)", "Deposit Amount"},
+		DateLayouts: []string{"02/01/2006", "02-01-2006", "02-Jan-2006"},
+	},
+	{
+		ID:          "sbi",
+		Name:        "State Bank of India",
+		Bank:        "SBI",
+		Markers:     []string{"state bank of india", "sbi"},
+		Date:        []string{"Txn Date", "Transaction Date", "Value Date"},
+		Description: []string{"Description", "Narration"},
+		Debit:       []string{"Debit", "Withdrawal"},
+		Credit:      []string{"Credit", "Deposit"},
+		DateLayouts: []string{"2 Jan 2006", "02 Jan 2006", "02-Jan-2006", "02/01/2006"},
+	},
+	{
+		ID:          "axis",
+		Name:        "Axis Bank",
+		Bank:        "Axis",
+		Markers:     []string{"axis bank", "axisbank"},
+		Date:        []string{"Tran Date", "Transaction Date", "Value Date"},
+		Description: []string{"PARTICULARS", "Description"},
+		Debit:       []string{"DR", "Debit", "Withdrawal Amt"},
+		Credit:      []string{"CR", "Credit", "Deposit Amt"},
+		DateLayouts: []string{"02-01-2006", "02/01/2006"},
+	},
+	{
+		ID:          "kotak",
+		Name:        "Kotak Mahindra Bank",
+		Bank:        "Kotak",
+		Markers:     []string{"kotak"},
+		Date:        []string{"Transaction Date", "Date", "Value Date"},
+		Description: []string{"Description", "Narration"},
+		Amount:      []string{"Amount"},
+		Debit:       []string{"Debit", "Withdrawal (Dr)"},
+		Credit:      []string{"Credit", "Deposit (Cr)"},
+		DrCr:        []string{"Dr / Cr", "Dr/Cr", "DR/CR"},
+		DateLayouts: []string{"02-01-2006", "02/01/2006", "02-Jan-2006", "02 Jan 2006"},
+	},
+}
+
+// Profiles returns the known bank profiles followed by the generic layout.
+func Profiles() []StatementProfile {
+	return append(append([]StatementProfile(nil), bankProfiles...), genericProfile)
+}
+
+// profileByID looks a profile up by its ID, case-insensitively.
+func profileByID(id string) (StatementProfile, bool) {
+	for _, p := range Profiles() {
+		if strings.EqualFold(p.ID, id) {
+			return p, true
+		}
+	}
+	return StatementProfile{}, false
+}
+
+// mappingProfile turns a user's column mapping into a profile with one alias
+// per field.
+func mappingProfile(id, name string, m models.ColumnMapping) StatementProfile {
+	alias := func(header string) []string {
+		if header == "" {
+			return nil
+		}
+		return []string{header}
+	}
+	p := StatementProfile{
+		ID:          id,
+		Name:        name,
+		Date:        alias(m.Date),
+		Description: alias(m.Description),
+		Amount:      alias(m.Amount),
+		Debit:       alias(m.Debit),
+		Credit:      alias(m.Credit),
+		Category:    alias(m.Category),
+		Currency:    alias(m.Currency),
+		Time:        alias(m.Time),
+	}
+	if m.DateFormat != "" {
+		p.DateLayouts = []string{dateLayoutFromPattern(m.DateFormat)}
+	}
+	return p
+}
+
+// headerSignature identifies a header row regardless of case, punctuation and
+// blank columns, so templates survive cosmetic changes to an export.
+func headerSignature(header []string) string {
+	var names []string
+	for _, col := range header {
+		if name := normalizeHeader(col); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// columnMap holds the resolved column index of every field, -1 when absent.
+type columnMap struct {
+	date, description, amount, debit, credit, drcr, category, currency, time int
+}
+
+// mapping names the header of every resolved column.
+func (m columnMap) mapping(header []string) models.ColumnMapping {
+	return models.ColumnMapping{
+		Date:        cell(header, m.date),
+		Description: cell(header, m.description),
+		Amount:      cell(header, m.amount),
+		Debit:       cell(header, m.debit),
+		Credit:      cell(header, m.credit),
+		Category:    cell(header, m.category),
+		Currency:    cell(header, m.currency),
+		Time:        cell(header, m.time),
+	}
+}
+
+func (m columnMap) valid() bool {
+	return m.date >= 0 && m.description >= 0 && (m.amount >= 0 || m.debit >= 0 || m.credit >= 0)
+}
+
+// match resolves the profile's aliases against a header row.
+func (p StatementProfile) match(header []string) columnMap {
+	index := make(map[string]int, len(header))
+	for i, col := range header {
+		key := normalizeHeader(col)
+		if _, seen := index[key]; !seen && key != "" {
+			index[key] = i
+		}
+	}
+	find := func(aliases []string) int {
+		for _, alias := range aliases {
+			if i, ok := index[normalizeHeader(alias)]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+	return columnMap{
+		date:        find(p.Date),
+		description: find(p.Description),
+		amount:      find(p.Amount),
+		debit:       find(p.Debit),
+		credit:      find(p.Credit),
+		drcr:        find(p.DrCr),
+		category:    find(p.Category),
+		currency:    find(p.Currency),
+		time:        find(p.Time),
+	}
+}
+
+// detectProfile picks the profile for a header row. A bank named in the preamble
+// wins; otherwise the generic layout, then the first bank whose columns all
+// resolve. A plain Date/Description/Amount file says nothing about its bank.
+func detectProfile(header []string, preamble string) (StatementProfile, columnMap, bool) {
+	preamble = strings.ToLower(preamble)
+	var fallback *StatementProfile
+	var fallbackCols columnMap
+
+	for _, p := range Profiles() {
+		cols := p.match(header)
+		if !cols.valid() {
+			continue
+		}
+		for _, marker := range p.Markers {
+			if strings.Contains(preamble, marker) {
+				return p, cols, true
+			}
+		}
+		if fallback == nil || p.ID == genericProfile.ID {
+			fallback = &p
+			fallbackCols = cols
+		}
+	}
+	if fallback == nil {
+		return StatementProfile{}, columnMap{}, false
+	}
+	return *fallback, fallbackCols, true
+}
+
+// normalizeHeader lowercases a column name and drops everything but letters and
+// digits, so "Withdrawal Amt." and "withdrawal amt" compare equal.
+func normalizeHeader(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// cell returns record[i], or "" when the row is too short or i is unset.
+func cell(record []string, i int) string {
+	if i < 0 || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+// qifDateLayouts covers the US-style dates Quicken writes, including the
+// apostrophe form used for years after 1999 ("1/15'26").
+var qifDateLayouts = []string{"1/2/2006", "1/2/06", "1/2'06", "2/1'06"}
+
+// StreamQIF reads a Quicken Interchange Format file and calls fn for every entry.
+// The raw L (category) line is kept in Expense.Category so CategorizeExpenses can
+// map it onto our own categories. Quicken writes MM/DD dates, but files from
+// other tools are day-first, so the layout is inferred like StreamCSV does.
+func (p *ParserService) StreamQIF(ctx context.Context, r io.Reader, opts ParseOptions, fn ExpenseFunc) (*models.ParseReport, error) {
+	rep := newReporter(FormatQIF)
+	scanner := bufio.NewScanner(r)
+	fields := make(map[byte]string)
+	line, start := 0, 1
+	// An !Account block names the account the transactions after it belong to.
+	var account string
+	inAccount := false
+
+	locale := opts.Locale
+	if locale == "" {
+		locale = "en-US"
+	}
+	dates := newDateInferrer(qifDateLayouts, opts.DateFormat, locale)
+	type pendingTxn struct {
+		line    int
+		fields  map[byte]string
+		account string
+	}
+	var pending []pendingTxn
+
+	emit := func(line int, fields map[byte]string, account string) error {
+		expense, layout, err := qifExpense(fields, dates)
+		if err != nil {
+			rep.skip(line, err)
+			return nil
+		}
+		rep.imported(layout)
+		expense.Account = account
+		return fn(expense)
+	}
+	flush := func() error {
+		for _, txn := range pending {
+			if err := emit(txn.line, txn.fields, txn.account); err != nil {
+				return err
+			}
+		}
+		pending = nil
+		return nil
+	}
+
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		line++
+
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		if text[0] == '!' {
+			// Headers such as !Type:Bank; !Account starts an account block.
+			inAccount = strings.EqualFold(strings.TrimSpace(text), "!Account")
+			continue
+		}
+		if len(fields) == 0 {
+			start = line
+		}
+		if text[0] != '^' {
+			// Split lines (S, E, $) repeat per split; the first value is enough.
+			if _, seen := fields[text[0]]; !seen {
+				fields[text[0]] = strings.TrimSpace(text[1:])
+			}
+			continue
+		}
+
+		txn := fields
+		fields = make(map[byte]string)
+		if inAccount {
+			account = txn['N']
+			continue
+		}
+		if !dates.decided() {
+			dates.observe(txn['D'])
+			pending = append(pending, pendingTxn{start, txn, account})
+			if dates.decided() {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if err := emit(start, txn, account); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	rep.dateAlternatives(dates)
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return rep.finish(), nil
+}
+
+func qifExpense(fields map[byte]string, dates *dateInferrer) (models.Expense, string, error) {
+	raw := fields['T']
+	if raw == "" {
+		raw = fields['U']
+	}
+	amt, err := parseAmount(raw)
+	if err != nil {
+		return models.Expense{}, "", skipRow(SkipInvalidAmount, "unrecognised amount %q", raw)
+	}
+	if amt.Value == 0 {
+		return models.Expense{}, "", skipRowWarning(SkipZeroAmount, "amount is zero")
+	}
+	amount, kind := direction(-amt.Value) // Payments are negative in QIF
+	kind = amt.kind(kind)
+
+	date, layout, err := dates.parse(fields['D'])
+	if err != nil {
+		return models.Expense{}, "", skipRow(SkipInvalidDate, "date %q does not match %s", fields['D'], describeLayout(layout))
+	}
+
+	description := fields['P']
+	if memo := fields['M']; memo != "" && !strings.EqualFold(memo, description) {
+		description = strings.TrimSpace(description + " " + memo)
+	}
+
+	category := fields['L']
+	if strings.HasPrefix(category, "[") {
+		category = "" // [Account] marks a transfer, not a category
+	}
+
+	return models.Expense{
+		Date:        date,
+		Description: description,
+		Amount:      amount,
+		Kind:        kind,
+		Category:    category,
+	}, layout, nil
+}
+
+// WriteQIF exports expenses as a !Type:Bank QIF file, translating categories to
+// their Quicken names.
+func (c *CategorizerService) WriteQIF(w io.Writer, expenses []models.Expense) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "!Type:Bank")
+	for _, exp := range expenses {
+		fmt.Fprintf(bw, "D%s\n", exp.Date.Format("01/02/2006"))
+		amount := -exp.Amount
+		if exp.IsCredit() {
+			amount = exp.Amount
+		}
+		fmt.Fprintf(bw, "T%.2f\n", amount)
+		fmt.Fprintf(bw, "P%s\n", qifText(exp.Description))
+		if exp.Category != "" {
+			fmt.Fprintf(bw, "L%s\n", c.QIFCategory(exp.Category))
+		}
+		fmt.Fprintln(bw, "^")
+	}
+	return bw.Flush()
+}
+
+// qifText keeps a value on one line so it cannot start a new field.
+func qifText(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"cmp"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+// refundWindow is how long after a purchase its refund may arrive. Returns,
+// cancelled bookings and chargebacks usually settle within a couple of months.
+const refundWindow = 90 * 24 * time.Hour
+
+// unmarkedRefundWindow bounds a credit that does not read like a refund: only
+// the full amount back from the same merchant soon after counts, such as a
+// cancelled order.
+const unmarkedRefundWindow = 14 * 24 * time.Hour
+
+// maxRefundCandidates bounds how many purchases per index bucket one credit is
+// weighed against, so large statements stay fast.
+const maxRefundCandidates = 16
+
+// refundWords mark a credit as money given back for a purchase.
+var refundWords = regexp.MustCompile(`(?i)\b(refund(ed)?|rfnd|reversal|reversed|rev|returned?|chargeback|cancell?ed|cancellation)\b`)
+
+// merchantNoise are words in descriptions that say how money moved, not who
+// it was paid to, so they do not count as a shared merchant.
+var merchantNoise = map[string]bool{
+	"upi": true, "pos": true, "ecom": true, "imps": true, "neft": true, "nach": true,
+	"ref": true, "txn": true, "payment": true, "purchase": true, "order": true,
+	"debit": true, "credit": true, "card": true, "via": true, "from": true, "for": true,
+	"the": true, "pvt": true, "ltd": true, "private": true, "limited": true,
+	"india": true, "internet": true, "www": true, "com": true, "and": true,
+}
+
+type RefundService struct{}
+
+func NewRefundService() *RefundService {
+	return &RefundService{}
+}
+
+// Match links credits to the purchases they refund through RefundOf. A refund
+// shares a merchant word with the purchase and comes after it. A credit that
+// reads like a refund may arrive within refundWindow and return all or part of
+// the purchase; any other credit must return the full amount within
+// unmarkedRefundWindow. Several partial refunds may settle one purchase, up to
+// its amount. A linked refund takes the purchase's category so it is netted
+// out of it. Earlier links are recomputed; transfers are left alone. It
+// returns the number of refunds linked.
+func (s *RefundService) Match(expenses []models.Expense) int {
+	type pair struct {
+		purchase, refund int
+		sameAccount      bool
+		fullAmount       bool
+		gap              time.Duration
+	}
+	dates := make([]time.Time, len(expenses))
+	merchants := make([][]string, len(expenses))
+	assignIDs(expenses)
+	for i := range expenses {
+		e := &expenses[i]
+		e.RefundOf = ""
+		dates[i] = calendarDay(e.Date)
+		merchants[i] = merchantWords(e.Description)
+	}
+
+	// Purchases by merchant word and by amount, in date order, so each credit
+	// is only compared with purchases it could refund.
+	byWord := make(map[string][]int)
+	byAmount := make(map[int64][]int)
+	for p, purchase := range expenses {
+		if purchase.IsCredit() || purchase.IsTransfer() || dates[p].IsZero() {
+			continue
+		}
+		for _, w := range merchants[p] {
+			if bucket := byWord[w]; len(bucket) == 0 || bucket[len(bucket)-1] != p {
+				byWord[w] = append(bucket, p)
+			}
+		}
+		byAmount[cents(purchase.Amount)] = append(byAmount[cents(purchase.Amount)], p)
+	}
+	byDate := func(a, b int) int { return dates[a].Compare(dates[b]) }
+	for _, bucket := range byWord {
+		slices.SortStableFunc(bucket, byDate)
+	}
+	for _, bucket := range byAmount {
+		slices.SortStableFunc(bucket, byDate)
+	}
+
+	var candidates []pair
+	for r, refund := range expenses {
+		if !refund.IsCredit() || refund.IsTransfer() || dates[r].IsZero() {
+			continue
+		}
+		hint := refundWords.MatchString(refund.Description)
+		window := unmarkedRefundWindow
+		if hint {
+			window = refundWindow
+		}
+		earliest := dates[r].Add(-window)
+
+		// Walk back from the refund through purchases of the same amount and,
+		// for a partial refund, through those sharing a merchant word. Only
+		// the latest few in each count: later ones are preferred anyway.
+		buckets := [][]int{byAmount[cents(refund.Amount)]}
+		if hint {
+			for _, w := range merchants[r] {
+				buckets = append(buckets, byWord[w])
+			}
+		}
+		seen := make(map[int]bool)
+		for _, bucket := range buckets {
+			end, _ := slices.BinarySearchFunc(bucket, dates[r].Add(time.Nanosecond), func(p int, t time.Time) int { return dates[p].Compare(t) })
+			found := 0
+			for i := end - 1; i >= 0 && found < maxRefundCandidates && !dates[bucket[i]].Before(earliest); i-- {
+				p := bucket[i]
+				if seen[p] {
+					continue
+				}
+				seen[p] = true
+				purchase := expenses[p]
+				full := cents(purchase.Amount) == cents(refund.Amount)
+				if !full && !(hint && refund.Amount < purchase.Amount) {
+					continue
+				}
+				if !sharesWord(merchants[p], merchants[r]) {
+					continue
+				}
+				candidates = append(candidates, pair{p, r, purchase.Account == refund.Account, full, dates[r].Sub(dates[p])})
+				found++
+			}
+		}
+	}
+
+	// Prefer the same account, then the exact amount, then the latest purchase.
+	slices.SortStableFunc(candidates, func(a, b pair) int {
+		if a.sameAccount != b.sameAccount {
+			if a.sameAccount {
+				return -1
+			}
+			return 1
+		}
+		if a.fullAmount != b.fullAmount {
+			if a.fullAmount {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(a.gap, b.gap)
+	})
+
+	refunded := make(map[int]float64)
+	matched := 0
+	for _, c := range candidates {
+		purchase, refund := &expenses[c.purchase], &expenses[c.refund]
+		if refund.IsRefund() || refunded[c.purchase]+refund.Amount > purchase.Amount+0.005 {
+			continue
+		}
+		refunded[c.purchase] += refund.Amount
+		refund.RefundOf = purchase.ID
+		refund.Category = purchase.Category
+		matched++
+	}
+	return matched
+}
+
+// isUnmatchedRefund reports whether a credit reads like a refund but no
+// purchase was found for it.
+func isUnmatchedRefund(e models.Expense) bool {
+	return e.IsCredit() && !e.IsRefund() && !e.IsTransfer() && refundWords.MatchString(e.Description)
+}
+
+// merchantWords are the words of a description that can name a merchant.
+func merchantWords(description string) []string {
+	var words []string
+	for _, w := range strings.Fields(normalizeDescription(description)) {
+		// Words with digits are references and masked card numbers.
+		if len(w) < 3 || merchantNoise[w] || refundWords.MatchString(w) || strings.ContainsAny(w, "0123456789") {
+			continue
+		}
+		words = append(words, w)
+	}
+	return words
+}
+
+// cents is an amount in whole hundredths, for comparing amounts exactly.
+func cents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func sharesWord(a, b []string) bool {
+	for _, w := range a {
+		if slices.Contains(b, w) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+// Reasons a row can be left out of an import, as counted in ParseReport.Skipped.
+const (
+	SkipInvalidAmount  = "invalid_amount"
+	SkipZeroAmount     = "zero_amount"
+	SkipInvalidDate    = "invalid_date"
+	SkipNotTransaction = "not_a_transaction"
+)
+
+// maxReportIssues caps the per-row issue list; Skipped keeps counting past it.
+const maxReportIssues = 200
+
+// skipError is returned by the per-row converters to explain why a row was not
+// imported. Warnings are expected skips (footers, credits); errors are rows that
+// looked like transactions but could not be read.
+type skipError struct {
+	reason  string
+	message string
+	warning bool
+}
+
+func (e *skipError) Error() string { return e.message }
+
+func skipRow(reason, format string, args ...any) error {
+	return &skipError{reason: reason, message: fmt.Sprintf(format, args...)}
+}
+
+func skipRowWarning(reason, format string, args ...any) error {
+	return &skipError{reason: reason, message: fmt.Sprintf(format, args...), warning: true}
+}
+
+// reporter accumulates a ParseReport while a file is streamed.
+type reporter struct {
+	report  models.ParseReport
+	layouts map[string]int
+}
+
+func newReporter(format string) *reporter {
+	return &reporter{
+		report:  models.ParseReport{Format: format, Skipped: make(map[string]int), Issues: []models.ParseIssue{}},
+		layouts: make(map[string]int),
+	}
+}
+
+// imported records a row that became an expense, and the date layout it used.
+func (r *reporter) imported(layout string) {
+	r.report.RowsRead++
+	r.report.RowsImported++
+	if layout != "" {
+		r.layouts[layout]++
+	}
+}
+
+// skip records a row that was left out. Errors that are not skipErrors are
+// reported as unreadable rows.
+func (r *reporter) skip(line int, err error) {
+	var se *skipError
+	if !errors.As(err, &se) {
+		se = &skipError{reason: SkipNotTransaction, message: err.Error()}
+	}
+	r.report.RowsRead++
+	r.report.Skipped[se.reason]++
+	r.issue(line, se)
+}
+
+// ignore counts a record that is clearly not a transaction (an SMS that is not
+// a bank alert, a blank row) without listing it as an issue.
+func (r *reporter) ignore(reason string) {
+	r.report.RowsRead++
+	r.report.Skipped[reason]++
+}
+
+func (r *reporter) issue(line int, se *skipError) {
+	if len(r.report.Issues) >= maxReportIssues {
+		r.report.IssuesTruncated = true
+		return
+	}
+	level := "error"
+	if se.warning {
+		level = "warning"
+	}
+	r.report.Issues = append(r.report.Issues, models.ParseIssue{
+		Line:    line,
+		Level:   level,
+		Reason:  se.reason,
+		Message: se.message,
+	})
+}
+
+// dateAlternatives flags a file whose dates fit more than one layout.
+func (r *reporter) dateAlternatives(d *dateInferrer) {
+	for _, layout := range d.alternatives() {
+		r.report.DateAlternatives = append(r.report.DateAlternatives, describeLayout(layout))
+	}
+	if len(r.report.DateAlternatives) > 0 {
+		r.issue(0, &skipError{
+			reason:  "ambiguous_dates",
+			message: fmt.Sprintf("dates also fit %s; assumed %s (set date_format to override)", strings.Join(r.report.DateAlternatives, ", "), describeLayout(d.layout())),
+			warning: true,
+		})
+	}
+}
+
+func (r *reporter) finish() *models.ParseReport {
+	best := 0
+	for layout, n := range r.layouts {
+		if n > best || (n == best && layout < r.report.DateFormat) {
+			best = n
+			r.report.DateFormat = layout
+		}
+	}
+	r.report.DateFormat = describeLayout(r.report.DateFormat)
+	return &r.report
+}
+
+// describeLayout turns a Go time layout into the DD/MM/YYYY notation users know.
+func describeLayout(layout string) string {
+	return strings.NewReplacer(
+		"2006", "YYYY", "January", "MONTH", "Jan", "MMM", "15", "hh", "04", "mm", "05", "ss",
+		"01", "MM", "02", "DD", "06", "YY", "1", "M", "2", "D",
+	).Replace(layout)
+}
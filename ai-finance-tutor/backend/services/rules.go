@@ -0,0 +1,280 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+// Rule priorities. Among the built-in rules merchants outrank the things they
+// sell, which outrank generic words, so "Amazon Prime" is a subscription and
+// "Grocery Shopping" is food.
+const (
+	PriorityGeneric  = 10 // Words like "store", "bill" or "ride"
+	PrioritySpecific = 20 // What was bought: "groceries", "petrol", "rent"
+	PriorityMerchant = 30 // Named merchants and brands
+	PriorityUser     = 40 // Default for rules the user adds, so they beat the built-ins
+)
+
+// categoryRule is a CategoryRule ready to match.
+type categoryRule struct {
+	models.CategoryRule
+	keywords []string // Lowercase
+	pattern  *regexp.Regexp
+}
+
+// compileRule checks a rule and prepares it for matching.
+func compileRule(r models.CategoryRule) (categoryRule, error) {
+	r.ID = strings.TrimSpace(r.ID)
+	r.Category = strings.TrimSpace(r.Category)
+	r.Pattern = strings.TrimSpace(r.Pattern)
+	if r.ID == "" {
+		return categoryRule{}, fmt.Errorf("rule id is required")
+	}
+	if r.Category == "" {
+		return categoryRule{}, fmt.Errorf("rule %s: category is required", r.ID)
+	}
+	if r.MinAmount < 0 || r.MaxAmount < 0 {
+		return categoryRule{}, fmt.Errorf("rule %s: amounts must not be negative", r.ID)
+	}
+	if r.MaxAmount > 0 && r.MinAmount > r.MaxAmount {
+		return categoryRule{}, fmt.Errorf("rule %s: min_amount %.2f is above max_amount %.2f", r.ID, r.MinAmount, r.MaxAmount)
+	}
+
+	c := categoryRule{CategoryRule: r}
+	c.Keywords = nil
+	for _, k := range r.Keywords {
+		k = strings.Join(strings.Fields(k), " ")
+		if k == "" {
+			continue
+		}
+		c.Keywords = append(c.Keywords, k)
+		c.keywords = append(c.keywords, strings.ToLower(k))
+	}
+	if r.Pattern != "" {
+		pattern, err := regexp.Compile("(?i)" + r.Pattern)
+		if err != nil {
+			return categoryRule{}, fmt.Errorf("rule %s: invalid pattern: %v", r.ID, err)
+		}
+		c.pattern = pattern
+	}
+	if len(c.keywords) == 0 && c.pattern == nil {
+		return categoryRule{}, fmt.Errorf("rule %s: keywords or pattern is required", r.ID)
+	}
+	return c, nil
+}
+
+// match returns the length of the longest keyword or pattern match in text,
+// which is lowercase, or 0 when the rule does not apply.
+func (r categoryRule) match(text string, amount float64) int {
+	if amount < r.MinAmount || (r.MaxAmount > 0 && amount > r.MaxAmount) {
+		return 0
+	}
+	best := 0
+	for _, k := range r.keywords {
+		if len(k) > best && containsWord(text, k) {
+			best = len(k)
+		}
+	}
+	if r.pattern != nil {
+		for _, loc := range r.pattern.FindAllStringIndex(text, -1) {
+			best = max(best, loc[1]-loc[0])
+		}
+	}
+	return best
+}
+
+// containsWord reports whether word occurs in text with no letter or digit
+// directly before or after it, so "ola" matches "OLA CABS" but not "Coca-Cola".
+func containsWord(text, word string) bool {
+	for from := 0; from < len(text); {
+		i := strings.Index(text[from:], word)
+		if i < 0 {
+			return false
+		}
+		start, end := from+i, from+i+len(word)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(text[start:])
+		from = start + size
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// bestRule finds the rule that decides text: the highest priority, then the
+// longest match, then the earliest in rules.
+func bestRule(rules []categoryRule, text string, amount float64) (categoryRule, bool) {
+	text = strings.ToLower(text)
+	var best categoryRule
+	bestLen := 0
+	for _, r := range rules {
+		n := r.match(text, amount)
+		if n == 0 {
+			continue
+		}
+		if bestLen == 0 || r.Priority > best.Priority || (r.Priority == best.Priority && n > bestLen) {
+			best, bestLen = r, n
+		}
+	}
+	return best, bestLen > 0
+}
+
+// Rules lists the categorization rules in the order they are tried.
+func (c *CategorizerService) Rules() []models.CategoryRule {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	rules := make([]models.CategoryRule, len(c.rules))
+	for i, r := range c.rules {
+		rules[i] = r.CategoryRule
+	}
+	return rules
+}
+
+// SaveRule adds a rule, or replaces the one with the same ID in place. A rule
+// without an ID is given one, and one without a priority gets PriorityUser.
+// Its category must exist; see AddCategory.
+func (c *CategorizerService) SaveRule(r models.CategoryRule) (models.CategoryRule, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	category, ok := c.category(r.Category)
+	if !ok {
+		return models.CategoryRule{}, fmt.Errorf("unknown category %q", r.Category)
+	}
+	r.Category = category
+	if r.Priority == 0 {
+		r.Priority = PriorityUser
+	}
+	r.ID = strings.TrimSpace(r.ID)
+	if r.ID == "" {
+		for n := len(c.rules) + 1; r.ID == "" || c.ruleIndex(r.ID) >= 0; n++ {
+			r.ID = "rule-" + strconv.Itoa(n)
+		}
+	}
+	rule, err := compileRule(r)
+	if err != nil {
+		return models.CategoryRule{}, err
+	}
+
+	if i := c.ruleIndex(rule.ID); i >= 0 {
+		c.rules[i] = rule
+	} else {
+		c.rules = append(c.rules, rule)
+	}
+	return rule.CategoryRule, nil
+}
+
+// DeleteRule removes a rule and reports whether it existed.
+func (c *CategorizerService) DeleteRule(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.ruleIndex(strings.TrimSpace(id))
+	if i < 0 {
+		return false
+	}
+	c.rules = slices.Delete(c.rules, i, i+1)
+	return true
+}
+
+func (c *CategorizerService) ruleIndex(id string) int {
+	return slices.IndexFunc(c.rules, func(r categoryRule) bool { return r.ID == id })
+}
+
+// Categories lists the spending categories, built-in ones first, with the
+// number of rules that assign each.
+func (c *CategorizerService) Categories() []models.Category {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	categories := make([]models.Category, 0, len(c.categories)+1)
+	for _, name := range append(slices.Clip(c.categories), "Misc") {
+		category := models.Category{Name: name, Custom: !c.builtIn(name)}
+		for _, r := range c.rules {
+			if r.Category == name {
+				category.Rules++
+			}
+		}
+		categories = append(categories, category)
+	}
+	return categories
+}
+
+// AddCategory creates a custom category for rules to assign.
+func (c *CategorizerService) AddCategory(name string) (models.Category, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case name == "":
+		return models.Category{}, fmt.Errorf("category name is required")
+	case len(name) > maxCategoryName:
+		return models.Category{}, fmt.Errorf("category name is longer than %d characters", maxCategoryName)
+	case strings.Contains(name, ":"):
+		return models.Category{}, fmt.Errorf("category name must not contain ':'")
+	case strings.EqualFold(name, "Income"):
+		return models.Category{}, fmt.Errorf("category Income is reserved for money in")
+	}
+	if existing, ok := c.category(name); ok {
+		return models.Category{}, fmt.Errorf("category %s already exists", existing)
+	}
+	c.categories = append(c.categories, name)
+	return models.Category{Name: name, Custom: true}, nil
+}
+
+// DeleteCategory removes a custom category and reports whether it existed.
+// Built-in categories and ones that rules still assign cannot be removed.
+func (c *CategorizerService) DeleteCategory(name string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name, ok := c.category(name)
+	if !ok {
+		return false, nil
+	}
+	if c.builtIn(name) {
+		return true, fmt.Errorf("%s is a built-in category", name)
+	}
+	var users []string
+	for _, r := range c.rules {
+		if r.Category == name {
+			users = append(users, r.ID)
+		}
+	}
+	if len(users) > 0 {
+		return true, fmt.Errorf("%s is still used by rules %s", name, strings.Join(users, ", "))
+	}
+	c.categories = slices.DeleteFunc(c.categories, func(s string) bool { return s == name })
+	return true, nil
+}
+
+// maxCategoryName bounds the length of a custom category name.
+const maxCategoryName = 40
+
+// category finds a category by name, ignoring case. Misc always exists.
+func (c *CategorizerService) category(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if strings.EqualFold(name, "Misc") {
+		return "Misc", true
+	}
+	for _, category := range c.categories {
+		if strings.EqualFold(category, name) {
+			return category, true
+		}
+	}
+	return "", false
+}
+
+func (c *CategorizerService) builtIn(name string) bool {
+	return name == "Misc" || slices.Contains(defaultCategories, name)
+}
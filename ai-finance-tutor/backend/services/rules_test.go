@@ -0,0 +1,136 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+func compileRules(t *testing.T, rules ...models.CategoryRule) []categoryRule {
+	t.Helper()
+	compiled := make([]categoryRule, len(rules))
+	for i, r := range rules {
+		c, err := compileRule(r)
+		if err != nil {
+			t.Fatalf("compileRule(%s): %v", r.ID, err)
+		}
+		compiled[i] = c
+	}
+	return compiled
+}
+
+func TestBestRule(t *testing.T) {
+	rules := compileRules(t,
+		models.CategoryRule{ID: "shop", Category: "Shopping", Priority: PriorityMerchant, Keywords: []string{"amazon"}},
+		models.CategoryRule{ID: "prime", Category: "Subscriptions", Priority: PriorityMerchant, Keywords: []string{"amazon prime"}},
+		models.CategoryRule{ID: "generic", Category: "Shopping", Priority: PriorityGeneric, Keywords: []string{"shopping"}},
+		models.CategoryRule{ID: "grocery", Category: "Food", Priority: PrioritySpecific, Keywords: []string{"grocery"}},
+		models.CategoryRule{ID: "first", Category: "Transport", Priority: PriorityMerchant, Keywords: []string{"uber"}},
+		models.CategoryRule{ID: "second", Category: "Food", Priority: PriorityMerchant, Keywords: []string{"uber"}},
+		models.CategoryRule{ID: "deposit", Category: "Rent", Priority: PrioritySpecific, Keywords: []string{"deposit"}, MinAmount: 5000},
+		models.CategoryRule{ID: "small", Category: "Misc", Priority: PrioritySpecific, Keywords: []string{"tip"}, MaxAmount: 100},
+		models.CategoryRule{ID: "recharge", Category: "Utilities", Priority: PrioritySpecific, Pattern: `\b(?:jio|airtel)\s+recharge\b`},
+	)
+
+	tests := []struct {
+		name        string
+		description string
+		amount      float64
+		want        string // Rule ID; empty when no rule matches
+	}{
+		{"longest match wins at equal priority", "Amazon Prime membership", 1499, "prime"},
+		{"shorter keyword alone", "Amazon order", 500, "shop"},
+		{"higher priority beats longer match", "Grocery Shopping", 800, "grocery"},
+		{"list order breaks full ties", "UBER TRIP", 200, "first"},
+		{"whole words only", "Tuber farm", 200, ""},
+		{"punctuation is a boundary", "UPI/uber@axis", 200, "first"},
+		{"case is ignored", "AMAZON", 500, "shop"},
+		{"min amount met", "Flat security deposit", 30000, "deposit"},
+		{"min amount at the bound", "Deposit", 5000, "deposit"},
+		{"below min amount", "Hotel deposit", 2000, ""},
+		{"max amount met", "Tip", 50, "small"},
+		{"above max amount", "Tip", 500, ""},
+		{"pattern", "Airtel  recharge", 299, "recharge"},
+		{"pattern respects word boundaries", "airtel recharged", 299, ""},
+		{"no match", "Theatre tickets", 600, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, ok := bestRule(rules, tt.description, tt.amount)
+			got := ""
+			if ok {
+				got = rule.ID
+			}
+			if got != tt.want {
+				t.Errorf("bestRule(%q, %.2f) = %q, want %q", tt.description, tt.amount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainsWord(t *testing.T) {
+	tests := []struct {
+		text, word string
+		want       bool
+	}{
+		{"uber trip", "uber", true},
+		{"tuber", "uber", false},
+		{"uberx", "uber", false},
+		{"coca-cola", "ola", false},
+		{"ola cabs", "ola", true},
+		{"domino's pizza", "domino", true},
+		{"theatre", "eat", false},
+		{"eat, then eat", "eat", true},
+		{"x tuber uber", "uber", true}, // A later occurrence can still match
+		{"café", "caf", false},
+		{"", "uber", false},
+	}
+	for _, tt := range tests {
+		if got := containsWord(tt.text, tt.word); got != tt.want {
+			t.Errorf("containsWord(%q, %q) = %v, want %v", tt.text, tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultRules(t *testing.T) {
+	c := NewCategorizerService()
+	tests := []struct {
+		description string
+		amount      float64
+		category    string
+		rule        string
+	}{
+		{"Amazon Prime membership", 1499, "Subscriptions", "subscriptions-streaming"},
+		{"Grocery Shopping", 800, "Food", "food-groceries"},
+		{"Coca-Cola vending", 40, "Misc", ""},
+		{"Flat security deposit", 30000, "Rent", "rent-deposit"},
+		{"Hotel deposit", 2000, "Misc", ""},
+		{"Uber Eats", 250, "Food", "food-delivery"},
+		{"Airtel prepaid recharge", 299, "Utilities", "utilities-recharge"},
+	}
+	for _, tt := range tests {
+		category, rule := c.Categorize(tt.description, tt.amount)
+		if category != tt.category || rule != tt.rule {
+			t.Errorf("Categorize(%q, %.2f) = %q, %q; want %q, %q", tt.description, tt.amount, category, rule, tt.category, tt.rule)
+		}
+	}
+}
+
+func TestCompileRuleErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule models.CategoryRule
+	}{
+		{"no id", models.CategoryRule{Category: "Food", Keywords: []string{"x"}}},
+		{"no category", models.CategoryRule{ID: "r", Keywords: []string{"x"}}},
+		{"nothing to match", models.CategoryRule{ID: "r", Category: "Food", Keywords: []string{" "}}},
+		{"bad pattern", models.CategoryRule{ID: "r", Category: "Food", Pattern: "("}},
+		{"negative amount", models.CategoryRule{ID: "r", Category: "Food", Keywords: []string{"x"}, MinAmount: -1}},
+		{"inverted range", models.CategoryRule{ID: "r", Category: "Food", Keywords: []string{"x"}, MinAmount: 10, MaxAmount: 5}},
+	}
+	for _, tt := range tests {
+		if _, err := compileRule(tt.rule); err == nil {
+			t.Errorf("%s: compileRule succeeded, want an error", tt.name)
+		}
+	}
+}
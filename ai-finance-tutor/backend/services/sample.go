@@ -0,0 +1,326 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+// Sample data defaults and limits.
+const (
+	DefaultSamplePersona = "young_professional"
+	DefaultSampleMonths  = 3
+	maxSampleMonths      = 24
+)
+
+// SampleOptions selects what GenerateSampleData produces. The same options
+// produce the same transactions only when End is set; a zero End follows the
+// clock, so the data moves along a month at a time.
+type SampleOptions struct {
+	Persona string // "student", "young_professional" or "family"
+	Months  int    // How many calendar months, ending with End
+	Seed    uint64
+	End     time.Time // Any day in the last month; zero means last month
+}
+
+// samplePersona describes a household's money: what comes in, the fixed
+// costs, the subscriptions and the day-to-day spending.
+type samplePersona struct {
+	ID            string
+	Bank          string // Account label for the salary account
+	Card          string // Account label for the credit card; empty means none
+	Income        []sampleFixed
+	Fixed         []sampleFixed
+	Subscriptions []sampleSubscription
+	Spending      []sampleSpend
+	Outliers      []sampleSpend // One-off large purchases, a couple per dataset
+}
+
+type sampleFixed struct {
+	Description string
+	Day         int
+	Amount      float64
+}
+
+type sampleSubscription struct {
+	Description string
+	Day         int
+	Price       float64
+	HikeTo      float64 // Price from the middle of the period on; zero means no hike
+}
+
+type sampleSpend struct {
+	Descriptions []string
+	PerMonth     float64 // Average number of purchases a month
+	Min, Max     float64
+	OnCard       bool  // Paid by credit card when the persona has one
+	Refundable   bool  // Now and then returned and refunded
+	Hours        []int // Hours of the day purchases happen at, picked at random; empty means no time
+}
+
+var samplePersonas = []samplePersona{
+	{
+		ID:   "student",
+		Bank: "SBI XX4417",
+		Income: []sampleFixed{
+			{"UPI from Dad - monthly allowance", 1, 12000},
+			{"Scholarship credit - NSP", 10, 2500},
+		},
+		Fixed: []sampleFixed{
+			{"Hostel rent - Sunrise PG", 3, 6500},
+		},
+		Subscriptions: []sampleSubscription{
+			{"Spotify Premium Student", 8, 59, 0},
+			{"YouTube Premium", 14, 129, 149},
+			{"Airtel mobile recharge", 20, 299, 349},
+		},
+		Spending: []sampleSpend{
+			{Descriptions: []string{"Zomato order", "Swiggy order", "Domino's Pizza"}, PerMonth: 9, Min: 120, Max: 450, Hours: []int{13, 20, 21, 22, 23, 23, 0, 1, 2}},
+			{Descriptions: []string{"Campus canteen meal", "Chai and snacks - Food court"}, PerMonth: 14, Min: 30, Max: 120, Hours: []int{9, 11, 13, 16, 17}},
+			{Descriptions: []string{"Metro card top-up", "Rapido bike taxi", "Uber ride"}, PerMonth: 8, Min: 40, Max: 250},
+			{Descriptions: []string{"Amazon order", "Myntra clothing"}, PerMonth: 1.5, Min: 300, Max: 1800, Refundable: true},
+			{Descriptions: []string{"Xerox and stationery", "BookMyShow tickets"}, PerMonth: 3, Min: 50, Max: 400},
+		},
+		Outliers: []sampleSpend{
+			{Descriptions: []string{"Flipkart - laptop purchase"}, Min: 42000, Max: 55000},
+			{Descriptions: []string{"Goa trip - train tickets IRCTC"}, Min: 3500, Max: 6000},
+		},
+	},
+	{
+		ID:   "young_professional",
+		Bank: "HDFC XX5678",
+		Card: "ICICI Card XX1234",
+		Income: []sampleFixed{
+			{"Salary ACME Technologies Pvt Ltd", 1, 85000},
+		},
+		Fixed: []sampleFixed{
+			{"Rent to landlord - NEFT", 5, 22000},
+			{"Airtel broadband bill", 12, 799},
+			{"Electricity bill BESCOM", 18, 1400},
+		},
+		Subscriptions: []sampleSubscription{
+			{"Netflix Subscription", 7, 199, 249},
+			{"Spotify Premium", 11, 119, 0},
+			{"Hotstar Premium", 15, 299, 0},
+			{"Gym membership - Cult.fit", 2, 1499, 0},
+		},
+		Spending: []sampleSpend{
+			{Descriptions: []string{"Zomato order", "Swiggy order", "Burger King"}, PerMonth: 12, Min: 180, Max: 900, OnCard: true, Hours: []int{13, 14, 20, 21, 22, 23, 0}},
+			{Descriptions: []string{"Starbucks Coffee", "Third Wave Coffee"}, PerMonth: 8, Min: 220, Max: 480, OnCard: true, Hours: []int{8, 9, 10, 16, 17}},
+			{Descriptions: []string{"Uber ride", "Rapido bike taxi", "Metro card top-up"}, PerMonth: 14, Min: 60, Max: 450, Hours: []int{9, 10, 18, 19, 20, 23}},
+			{Descriptions: []string{"Amazon order", "Myntra clothing", "Flipkart order"}, PerMonth: 3, Min: 500, Max: 4500, OnCard: true, Refundable: true},
+			{Descriptions: []string{"Swiggy Instamart", "DMart groceries"}, PerMonth: 4, Min: 400, Max: 1800},
+			{Descriptions: []string{"BookMyShow tickets", "Weekend brunch - restaurant"}, PerMonth: 3, Min: 400, Max: 2500, OnCard: true},
+		},
+		Outliers: []sampleSpend{
+			{Descriptions: []string{"Croma electronics store - laptop"}, Min: 65000, Max: 80000, OnCard: true},
+			{Descriptions: []string{"MakeMyTrip - flight travel"}, Min: 8000, Max: 16000, OnCard: true},
+		},
+	},
+	{
+		ID:   "family",
+		Bank: "Axis XX9021",
+		Card: "HDFC Card XX3310",
+		Income: []sampleFixed{
+			{"Salary Infosys Ltd", 1, 140000},
+			{"Salary credit - spouse - Apollo Hospitals", 1, 62000},
+		},
+		Fixed: []sampleFixed{
+			{"Rent to landlord - NEFT", 4, 35000},
+			{"School fees - Delhi Public School", 10, 9500},
+			{"Electricity bill TPDDL", 15, 3800},
+			{"Water bill DJB", 15, 450},
+			{"Jio fiber broadband bill", 20, 999},
+		},
+		Subscriptions: []sampleSubscription{
+			{"Netflix Premium", 6, 649, 799},
+			{"Disney Hotstar Subscription", 13, 299, 0},
+			{"Prime Video membership", 22, 299, 0},
+		},
+		Spending: []sampleSpend{
+			{Descriptions: []string{"BigBasket supermarket", "DMart groceries", "Reliance Fresh store"}, PerMonth: 8, Min: 900, Max: 4200, Hours: []int{10, 11, 17, 18, 19}},
+			{Descriptions: []string{"Zomato order", "Swiggy order", "Pizza Hut"}, PerMonth: 6, Min: 350, Max: 1400, OnCard: true, Hours: []int{13, 20, 21}},
+			{Descriptions: []string{"Petrol - Indian Oil", "Petrol - HP pump", "Parking charges"}, PerMonth: 6, Min: 150, Max: 3500, OnCard: true},
+			{Descriptions: []string{"Amazon order", "Flipkart order", "Lifestyle store clothing"}, PerMonth: 4, Min: 600, Max: 6000, OnCard: true, Refundable: true},
+			{Descriptions: []string{"Pharmacy - Apollo", "Kids tuition - maths"}, PerMonth: 3, Min: 300, Max: 3000},
+		},
+		Outliers: []sampleSpend{
+			{Descriptions: []string{"Car service - Maruti workshop"}, Min: 9000, Max: 16000, OnCard: true},
+			{Descriptions: []string{"Hospital - Max Healthcare"}, Min: 25000, Max: 60000},
+			{Descriptions: []string{"Diwali shopping - Croma electronics store"}, Min: 30000, Max: 55000, OnCard: true},
+		},
+	},
+}
+
+// SamplePersonas lists the persona IDs GenerateSampleData accepts.
+func SamplePersonas() []string {
+	ids := make([]string, len(samplePersonas))
+	for i, p := range samplePersonas {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+// GenerateSampleData makes up a few months of a persona's transactions for the
+// demo: monthly income, rent and bills, recurring subscriptions (one of which
+// gets more expensive halfway through), everyday spending, a couple of
+// outliers, the odd refund and, for personas with a credit card, the monthly
+// card bill paid from the bank account.
+func (p *ParserService) GenerateSampleData(opts SampleOptions) ([]models.Expense, error) {
+	id := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(opts.Persona)), "-", "_")
+	if id == "" {
+		id = DefaultSamplePersona
+	}
+	i := slices.IndexFunc(samplePersonas, func(p samplePersona) bool { return p.ID == id })
+	if i < 0 {
+		return nil, fmt.Errorf("unknown persona %q: choose one of %s", opts.Persona, strings.Join(SamplePersonas(), ", "))
+	}
+	persona := samplePersonas[i]
+
+	months := opts.Months
+	if months == 0 {
+		months = DefaultSampleMonths
+	}
+	if months < 1 || months > maxSampleMonths {
+		return nil, fmt.Errorf("months must be between 1 and %d", maxSampleMonths)
+	}
+	end := opts.End
+	if end.IsZero() {
+		now := time.Now()
+		end = time.Date(now.Year(), now.Month(), 0, 0, 0, 0, 0, time.UTC)
+	}
+	first := time.Date(end.Year(), end.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -(months - 1), 0)
+
+	g := &sampleGenerator{
+		rng:     rand.New(rand.NewPCG(opts.Seed, 0)),
+		persona: persona,
+		until:   first.AddDate(0, months, 0),
+	}
+	for m := 0; m < months; m++ {
+		g.month(first.AddDate(0, m, 0), m >= (months+1)/2 && months > 1)
+	}
+	g.outliers(first, months)
+	g.cardBills(first, months)
+
+	sortExpenses(g.expenses)
+	return g.expenses, nil
+}
+
+type sampleGenerator struct {
+	rng      *rand.Rand
+	persona  samplePersona
+	expenses []models.Expense
+	until    time.Time // First day after the period
+}
+
+func (g *sampleGenerator) add(date time.Time, description string, amount float64, kind string, onCard bool) {
+	if !date.Before(g.until) {
+		return
+	}
+	account := g.persona.Bank
+	if onCard && g.persona.Card != "" {
+		account = g.persona.Card
+	}
+	g.expenses = append(g.expenses, models.Expense{
+		Date:        date,
+		Description: description,
+		Amount:      math.Round(amount*100) / 100,
+		Kind:        kind,
+		Account:     account,
+		Currency:    "INR",
+	})
+}
+
+// month fills in one calendar month starting at start.
+func (g *sampleGenerator) month(start time.Time, hiked bool) {
+	days := start.AddDate(0, 1, -1).Day()
+	day := func(d int) time.Time { return start.AddDate(0, 0, min(d, days)-1) }
+
+	for _, in := range g.persona.Income {
+		g.add(day(in.Day), in.Description, in.Amount, models.KindCredit, false)
+	}
+	for _, f := range g.persona.Fixed {
+		// Bills vary a little from month to month; rent does not.
+		amount := f.Amount
+		if !strings.Contains(strings.ToLower(f.Description), "rent") {
+			amount = math.Round(amount * (0.9 + 0.2*g.rng.Float64()))
+		}
+		g.add(day(f.Day), f.Description, amount, models.KindDebit, false)
+	}
+	for _, s := range g.persona.Subscriptions {
+		price := s.Price
+		if hiked && s.HikeTo > 0 {
+			price = s.HikeTo
+		}
+		g.add(day(s.Day), s.Description, price, models.KindDebit, true)
+	}
+
+	for _, s := range g.persona.Spending {
+		n := int(s.PerMonth*(0.7+0.6*g.rng.Float64()) + 0.5)
+		for range n {
+			description := s.Descriptions[g.rng.IntN(len(s.Descriptions))]
+			amount := math.Round(s.Min + g.rng.Float64()*(s.Max-s.Min))
+			date := day(1 + g.rng.IntN(days))
+			if len(s.Hours) > 0 {
+				hour := s.Hours[g.rng.IntN(len(s.Hours))]
+				date = date.Add(time.Duration(hour)*time.Hour + time.Duration(1+g.rng.IntN(59))*time.Minute)
+			}
+			g.add(date, description, amount, models.KindDebit, s.OnCard)
+
+			// Roughly one return in twelve purchases, refunded a week or so later.
+			if s.Refundable && g.rng.IntN(12) == 0 {
+				g.add(calendarDay(date).AddDate(0, 0, 5+g.rng.IntN(8)), description+" refund", amount, models.KindCredit, s.OnCard)
+			}
+		}
+	}
+}
+
+// outliers adds one or two large one-off purchases somewhere in the period.
+func (g *sampleGenerator) outliers(first time.Time, months int) {
+	n := 1 + g.rng.IntN(2)
+	for _, i := range g.rng.Perm(len(g.persona.Outliers))[:min(n, len(g.persona.Outliers))] {
+		o := g.persona.Outliers[i]
+		start := first.AddDate(0, g.rng.IntN(months), 0)
+		date := start.AddDate(0, 0, g.rng.IntN(start.AddDate(0, 1, -1).Day()))
+		amount := math.Round(o.Min + g.rng.Float64()*(o.Max-o.Min))
+		g.add(date, o.Descriptions[0], amount, models.KindDebit, o.OnCard)
+	}
+}
+
+// cardBills pays each month's card spend, net of refunds, from the bank
+// account on the 5th of the next month.
+func (g *sampleGenerator) cardBills(first time.Time, months int) {
+	if g.persona.Card == "" {
+		return
+	}
+	statements := make([]float64, months)
+	for _, e := range g.expenses {
+		m := (e.Date.Year()-first.Year())*12 + int(e.Date.Month()-first.Month())
+		if e.Account != g.persona.Card || m < 0 || m >= months {
+			continue
+		}
+		statements[m] += signedAmount(e)
+	}
+	issuer := strings.Fields(g.persona.Card)[0]
+	for m, total := range statements {
+		if total <= 0 {
+			continue
+		}
+		due := first.AddDate(0, m+1, 4)
+		g.add(due, "CREDIT CARD PAYMENT - "+issuer+" CC", total, models.KindDebit, false)
+		g.add(due.AddDate(0, 0, 1), "PAYMENT RECEIVED - THANK YOU", total, models.KindCredit, true)
+	}
+}
+
+// signedAmount is a debit's amount or minus a credit's.
+func signedAmount(e models.Expense) float64 {
+	if e.IsCredit() {
+		return -e.Amount
+	}
+	return e.Amount
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+// smsSenders maps the sender ID suffix of transactional SMS ("VM-HDFCBK",
+// "AD-ICICIT") to the bank or app that sent it.
+var smsSenders = map[string]string{
+	"HDFCBK": "HDFC", "HDFCBN": "HDFC",
+	"ICICIB": "ICICI", "ICICIT": "ICICI",
+	"SBIUPI": "SBI", "SBIINB": "SBI", "CBSSBI": "SBI", "ATMSBI": "SBI", "SBICRD": "SBI Card",
+	"AXISBK": "Axis", "AXISBN": "Axis",
+	"KOTAKB": "Kotak", "KOTAK": "Kotak",
+	"PNBSMS": "PNB", "BOBTXN": "Bank of Baroda", "YESBNK": "Yes Bank",
+	"IDFCFB": "IDFC First", "INDUSB": "IndusInd", "CANBNK": "Canara",
+	"PAYTMB": "Paytm", "PHONPE": "PhonePe", "GPAY": "Google Pay", "AMZPAY": "Amazon Pay",
+}
+
+// Debit alert phrasing differs by bank, but the pieces are the same. These
+// cover HDFC, ICICI, SBI, Axis, Kotak card/UPI alerts and Paytm, PhonePe and
+// Google Pay payment messages.
+var (
+	smsDebitWord  = regexp.MustCompile(`(?i)\b(debited|spent|sent|paid|withdrawn|debit of|purchase of|txn of)\b`)
+	smsCreditWord = regexp.MustCompile(`(?i)\b(credited to your|is credited|credited with|received|refund(ed)?|reversed|deposited)\b`)
+	smsIgnore     = regexp.MustCompile(`(?i)\b(otp|will be debited|is due|declined|failed|request(ed)? money|collect request)\b`)
+
+	smsAmount      = regexp.MustCompile(`(?i)(?:rs\.?|inr|₹)\s*([\d,]+(?:\.\d{1,2})?(?:\s*(?:cr|dr)\b)?)`)
+	smsAmountBare  = regexp.MustCompile(`(?i)debited\s+(?:by|for|with)\s+([\d,]+(?:\.\d{1,2})?)`)
+	smsAccount     = regexp.MustCompile(`(?i)\b(?:a/c|ac|acct|account|card)\s*(?:no\.?|ending(?: with)?)?\s*[:.]?\s*([x*]*\d{3,6})\b`)
+	smsVPA         = regexp.MustCompile(`\b([a-zA-Z0-9._\-]+@[a-zA-Z]{2,})\b`)
+	smsRef         = regexp.MustCompile(`(?i)(?:upi ref(?:erence)?(?: no)?|ref(?:\s*no)?|refno|upi|rrn|txn id)\s*[:.#]?\s*(\d{6,16})`)
+	smsAxisUPI     = regexp.MustCompile(`(?i)UPI/P2[AM]/(\d+)/([^/]+?)(?:\s+not you|/|$)`)
+	smsCreditedTo  = regexp.MustCompile(`(?i);\s*([^;.]+?)\s+credited\b`)
+	smsPaidBy      = regexp.MustCompile(`(?i)\b(?:from|by)\s+([A-Za-z0-9][A-Za-z0-9 &'._\-]*?)(?:\s+(?:on|ref|refno|ref no|via|upi|avl|avbl)\b|[.(;,]|$)`)
+	smsMerchant    = regexp.MustCompile(`(?i)\b(?:at|to|trf to|towards|paid to|info)\s*:?\s+([A-Za-z0-9][A-Za-z0-9 &'._*\-]*?)(?:\s+(?:on|ref|refno|ref no|via|using|from|upi|avl|avbl|thru|by)\b|[.(;,]|$)`)
+	smsDateInBody  = regexp.MustCompile(`\b(\d{4}-\d{2}-\d{2}|\d{1,2}[-/]\d{1,2}[-/]\d{2,4}|\d{1,2}[- ]?[A-Za-z]{3}[- ]?\d{2,4})\b`)
+	smsDateLayouts = []string{
+		"2006-01-02", "02-01-06", "02-01-2006", "02/01/06", "02/01/2006", "2-1-06", "2/1/06",
+		"02-Jan-06", "02-Jan-2006", "02Jan06", "02Jan2006", "02 Jan 06", "02 Jan 2006", "2 Jan 2006",
+	}
+)
+
+var ist = time.FixedZone("IST", 5*60*60+30*60)
+
+// StreamSMSBackup reads the XML written by Android "SMS Backup & Restore" and
+// calls fn for every bank or UPI debit or credit alert found in the inbox.
+func (p *ParserService) StreamSMSBackup(ctx context.Context, r io.Reader, fn ExpenseFunc) (*models.ParseReport, error) {
+	rep := newReporter(FormatSMS)
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false // Backups often contain unescaped characters in bodies
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return rep.finish(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "sms" {
+			continue
+		}
+
+		attrs := make(map[string]string, len(start.Attr))
+		for _, a := range start.Attr {
+			attrs[a.Name.Local] = a.Value
+		}
+		if attrs["type"] != "" && attrs["type"] != "1" {
+			continue // Only received messages
+		}
+
+		line, _ := decoder.InputPos()
+		expense, layout, err := smsExpense(attrs["address"], attrs["body"], attrs["date"])
+		if err == errNotAlert {
+			rep.ignore(SkipNotTransaction) // Personal and promotional messages
+			continue
+		}
+		if err != nil {
+			rep.skip(line, err)
+			continue
+		}
+		rep.imported(layout)
+		if err := fn(expense); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// errNotAlert marks messages that are not bank or UPI alerts at all.
+var errNotAlert = errors.New("not a transaction alert")
+
+// smsExpense extracts a debit or credit from one message and reports the date layout
+// found in the body. receivedMillis is the epoch timestamp SMS Backup stores,
+// used when the body carries no date.
+func smsExpense(sender, body, receivedMillis string) (models.Expense, string, error) {
+	bank, known := smsSender(sender)
+	if !known && !strings.Contains(strings.ToLower(body), "a/c") && !strings.Contains(strings.ToLower(body), "upi") {
+		return models.Expense{}, "", errNotAlert
+	}
+	if smsIgnore.MatchString(body) {
+		return models.Expense{}, "", errNotAlert
+	}
+	debit := smsDebitWord.FindStringIndex(body)
+	credit := smsCreditWord.FindStringIndex(body)
+	if debit == nil && credit == nil {
+		return models.Expense{}, "", errNotAlert
+	}
+	// Debit alerts often name the payee as "credited" later on, so the first word wins.
+	kind := models.KindDebit
+	if debit == nil || (credit != nil && credit[0] < debit[0]) {
+		kind = models.KindCredit
+	}
+
+	m := smsAmount.FindStringSubmatch(body)
+	if m == nil {
+		m = smsAmountBare.FindStringSubmatch(body)
+	}
+	if m == nil {
+		return models.Expense{}, "", skipRow(SkipInvalidAmount, "no amount found in %s alert", kind)
+	}
+	amt, err := parseAmount(m[1])
+	if err != nil || amt.Value <= 0 {
+		return models.Expense{}, "", skipRow(SkipInvalidAmount, "unrecognised amount %q", m[1])
+	}
+	amount := amt.Value
+	kind = amt.kind(kind)
+
+	// Alerts arrive within moments of the transaction, so the time the message
+	// was received is its time of day, unless the body dates it another day.
+	var received time.Time
+	if millis, err := strconv.ParseInt(receivedMillis, 10, 64); err == nil {
+		received = time.UnixMilli(millis).In(ist)
+		received = time.Date(received.Year(), received.Month(), received.Day(),
+			received.Hour(), received.Minute(), received.Second(), 0, time.UTC)
+	}
+	var date time.Time
+	var layout string
+	if d := smsDateInBody.FindString(body); d != "" {
+		date, layout, _ = parseDateLayout(d, smsDateLayouts...)
+	}
+	switch {
+	case layout != "" && calendarDay(received).Equal(date):
+		date = received
+	case layout == "" && received.IsZero():
+		return models.Expense{}, "", skipRow(SkipInvalidDate, "no date in message or backup")
+	case layout == "":
+		date = received
+	}
+
+	var account string
+	if a := smsAccount.FindStringSubmatch(body); a != nil {
+		account = "XX" + strings.TrimLeft(a[1], "xX*")
+		if bank != "" {
+			account = bank + " " + account
+		}
+	}
+
+	var reference string
+	if ref := smsRef.FindStringSubmatch(body); ref != nil {
+		reference = ref[1]
+	} else if ref := smsAxisUPI.FindStringSubmatch(body); ref != nil {
+		reference = ref[1]
+	}
+
+	description := smsPayee(body)
+	if kind == models.KindCredit {
+		description = smsPayer(body)
+	}
+	if description == "" {
+		description = strings.TrimSpace(bank + " " + kind)
+	}
+
+	return models.Expense{
+		Date:          date,
+		Description:   description,
+		Amount:        amount,
+		Kind:          kind,
+		TransactionID: reference,
+		Account:       account,
+	}, layout, nil
+}
+
+func smsSender(address string) (string, bool) {
+	address = strings.ToUpper(address)
+	if i := strings.LastIndex(address, "-"); i >= 0 {
+		address = address[i+1:]
+	}
+	bank, ok := smsSenders[address]
+	return bank, ok
+}
+
+// smsPayee picks the merchant name or VPA the money went to.
+func smsPayee(body string) string {
+	if m := smsAxisUPI.FindStringSubmatch(body); m != nil {
+		return strings.TrimSpace(m[2])
+	}
+	if m := smsVPA.FindStringSubmatch(body); m != nil {
+		return m[1]
+	}
+	if m := smsCreditedTo.FindStringSubmatch(body); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	for _, m := range smsMerchant.FindAllStringSubmatch(body, -1) {
+		name := strings.TrimSpace(m[1])
+		lower := strings.ToLower(name)
+		if name == "" || smsDateInBody.MatchString(name) ||
+			strings.HasPrefix(lower, "your") || strings.HasPrefix(lower, "a/c") || strings.HasPrefix(lower, "ac ") ||
+			strings.HasPrefix(lower, "vpa") || strings.HasPrefix(lower, "date") {
+			continue
+		}
+		return name
+	}
+	return ""
+}
+
+// smsPayer picks the VPA or name the money came from.
+func smsPayer(body string) string {
+	if m := smsVPA.FindStringSubmatch(body); m != nil {
+		return m[1]
+	}
+	if m := smsPaidBy.FindStringSubmatch(body); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	return ""
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+// Profile IDs reported for imports that used a column mapping instead of a
+// built-in bank profile.
+const (
+	customProfileID   = "custom"
+	templateProfileID = "template"
+)
+
+// templateStore keeps saved mapping templates in memory, keyed by lowercase name.
+type templateStore struct {
+	mu     sync.RWMutex
+	byName map[string]models.MappingTemplate
+}
+
+func newTemplateStore() *templateStore {
+	return &templateStore{byName: make(map[string]models.MappingTemplate)}
+}
+
+func (s *templateStore) get(name string) (models.MappingTemplate, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.byName[strings.ToLower(strings.TrimSpace(name))]
+	return t, ok
+}
+
+// match finds the template saved for a header row.
+func (s *templateStore) match(header []string) (models.MappingTemplate, bool) {
+	signature := headerSignature(header)
+	if signature == "" {
+		return models.MappingTemplate{}, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, t := range s.byName {
+		if t.Signature == signature {
+			return t, true
+		}
+	}
+	return models.MappingTemplate{}, false
+}
+
+func templateProfile(t models.MappingTemplate) StatementProfile {
+	return mappingProfile(templateProfileID, t.Name, t.Mapping)
+}
+
+// SaveTemplate stores a mapping for files whose header row is headers. It
+// replaces any template with the same name or the same header signature, so
+// one header never has two competing templates.
+func (p *ParserService) SaveTemplate(name string, headers []string, mapping models.ColumnMapping) (models.MappingTemplate, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return models.MappingTemplate{}, fmt.Errorf("template name is required")
+	}
+	if len(headers) == 0 {
+		return models.MappingTemplate{}, fmt.Errorf("template headers are required")
+	}
+	if err := checkMapping(headers, mapping); err != nil {
+		return models.MappingTemplate{}, err
+	}
+
+	t := models.MappingTemplate{
+		Name:      name,
+		Signature: headerSignature(headers),
+		Headers:   headers,
+		Mapping:   mapping,
+	}
+	p.templates.mu.Lock()
+	defer p.templates.mu.Unlock()
+	for key, existing := range p.templates.byName {
+		if existing.Signature == t.Signature {
+			delete(p.templates.byName, key)
+		}
+	}
+	p.templates.byName[strings.ToLower(name)] = t
+	return t, nil
+}
+
+// Templates lists the saved templates by name.
+func (p *ParserService) Templates() []models.MappingTemplate {
+	p.templates.mu.RLock()
+	defer p.templates.mu.RUnlock()
+	templates := make([]models.MappingTemplate, 0, len(p.templates.byName))
+	for _, t := range p.templates.byName {
+		templates = append(templates, t)
+	}
+	slices.SortFunc(templates, func(a, b models.MappingTemplate) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	return templates
+}
+
+// DeleteTemplate removes a template and reports whether it existed.
+func (p *ParserService) DeleteTemplate(name string) bool {
+	key := strings.ToLower(strings.TrimSpace(name))
+	p.templates.mu.Lock()
+	defer p.templates.mu.Unlock()
+	_, ok := p.templates.byName[key]
+	delete(p.templates.byName, key)
+	return ok
+}
+
+// checkMapping makes sure a mapping names the fields an import needs and, when
+// headers are known, that every column it names is among them.
+func checkMapping(headers []string, m models.ColumnMapping) error {
+	switch {
+	case m.Date == "":
+		return fmt.Errorf("mapping needs a date column")
+	case m.Description == "":
+		return fmt.Errorf("mapping needs a description column")
+	case m.Amount == "" && m.Debit == "" && m.Credit == "":
+		return fmt.Errorf("mapping needs an amount, debit or credit column")
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+
+	known := make(map[string]bool, len(headers))
+	for _, h := range headers {
+		known[normalizeHeader(h)] = true
+	}
+	for _, col := range []string{m.Date, m.Description, m.Amount, m.Debit, m.Credit, m.Category, m.Currency, m.Time} {
+		if col != "" && !known[normalizeHeader(col)] {
+			return fmt.Errorf("column not in header: %q", col)
+		}
+	}
+	return nil
+}
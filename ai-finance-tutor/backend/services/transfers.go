@@ -0,0 +1,111 @@
+package services
+
+import (
+	"cmp"
+	"math"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+// transferWindow is how far apart the two legs of a transfer may post. Card
+// issuers credit a bill payment a few days after the bank debits it.
+const transferWindow = 4 * 24 * time.Hour
+
+// transferWords mark a description as a payment to one's own account: card bill
+// payments as the bank and the card issuer word them, and self-transfers.
+var transferWords = regexp.MustCompile(`(?i)\b(credit ?card|card ?payment|cc ?(bill|payment|pymt)|payment received|thank you|autopay|auto ?debit|bill ?desk|self|own a/?c|own account|transfer|trf|sweep)\b`)
+
+type TransferService struct{}
+
+func NewTransferService() *TransferService {
+	return &TransferService{}
+}
+
+// transferRails are the payment rails money moves between one's own accounts
+// on. A same-day pair that went over one on both sides needs no other hint.
+var transferRails = map[string]bool{RailUPI: true, RailNEFT: true, RailIMPS: true, RailRTGS: true}
+
+// Match pairs each debit with a credit of the same amount in another of the
+// user's accounts, posted within transferWindow, and links the two through
+// TransferOf. One side must read like a transfer, or, for a pair on the same
+// day, both sides must have gone over a bank transfer rail; equal amounts
+// alone are too common to count. Earlier links are recomputed, so Match can
+// run again after every upload. It returns the number of pairs found.
+func (s *TransferService) Match(expenses []models.Expense) int {
+	type leg struct {
+		index int
+		date  time.Time
+		hint  bool
+	}
+	var debits, credits []leg
+	assignIDs(expenses)
+	for i := range expenses {
+		e := &expenses[i]
+		e.TransferOf = ""
+		if e.Account == "" {
+			continue
+		}
+		if e.Date.IsZero() {
+			continue
+		}
+		l := leg{index: i, date: calendarDay(e.Date), hint: transferWords.MatchString(e.Description)}
+		if e.IsCredit() {
+			credits = append(credits, l)
+		} else {
+			debits = append(debits, l)
+		}
+	}
+
+	// Closest dates first, so each credit goes to the debit it most likely
+	// settles when several have the same amount.
+	type pair struct {
+		debit, credit int
+		gap           time.Duration
+		hint          bool
+	}
+	var candidates []pair
+	for _, d := range debits {
+		for _, c := range credits {
+			out, in := expenses[d.index], expenses[c.index]
+			if out.Account == in.Account || math.Abs(out.Amount-in.Amount) >= 0.005 {
+				continue
+			}
+			gap := c.date.Sub(d.date)
+			if gap < 0 {
+				gap = -gap
+			}
+			hint := d.hint || c.hint
+			sameRail := gap == 0 && transferRails[out.Rail] && transferRails[in.Rail]
+			if gap > transferWindow || !hint && !sameRail {
+				continue
+			}
+			candidates = append(candidates, pair{d.index, c.index, gap, hint})
+		}
+	}
+	slices.SortStableFunc(candidates, func(a, b pair) int {
+		if a.gap != b.gap {
+			return cmp.Compare(a.gap, b.gap)
+		}
+		if a.hint != b.hint {
+			if a.hint {
+				return -1
+			}
+			return 1
+		}
+		return 0
+	})
+
+	matched := 0
+	for _, p := range candidates {
+		out, in := &expenses[p.debit], &expenses[p.credit]
+		if out.IsTransfer() || in.IsTransfer() {
+			continue
+		}
+		out.TransferOf, in.TransferOf = in.ID, out.ID
+		matched++
+	}
+	return matched
+}
@@ -0,0 +1,312 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/siddhartharajbongshi/spendsense-backend/models"
+)
+
+// StreamXLSX reads one worksheet of an Excel workbook and feeds its rows through
+// the same header detection and column mapping as StreamCSV. opts.Sheet picks the
+// worksheet by name or 1-based position; the first sheet is used by default.
+// A zip archive needs random access, so the caller provides an io.ReaderAt.
+func (p *ParserService) StreamXLSX(ctx context.Context, r io.ReaderAt, size int64, opts ParseOptions, fn ExpenseFunc) (*models.ParseReport, error) {
+	rows, err := openXLSXSheet(r, size, opts.Sheet)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return p.streamRows(ctx, rows, FormatXLSX, opts, fn)
+}
+
+// openXLSXSheet returns a row reader over the chosen worksheet. The caller
+// closes it when done.
+func openXLSXSheet(r io.ReaderAt, size int64, want string) (*xlsxRowReader, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xlsx file: %v", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := xlsxSheetPath(files, want)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := xlsxSharedStrings(files)
+	if err != nil {
+		return nil, err
+	}
+	dateStyles, err := xlsxDateStyles(files)
+	if err != nil {
+		return nil, err
+	}
+
+	sheet, ok := files[sheetPath]
+	if !ok {
+		return nil, fmt.Errorf("xlsx worksheet not found: %s", sheetPath)
+	}
+	rc, err := sheet.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &xlsxRowReader{
+		sheet:      rc,
+		decoder:    xml.NewDecoder(rc),
+		shared:     shared,
+		dateStyles: dateStyles,
+	}, nil
+}
+
+// xlsxSheetPath resolves the worksheet part for a sheet name or 1-based index.
+func xlsxSheetPath(files map[string]*zip.File, want string) (string, error) {
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := decodeZipXML(files, "xl/workbook.xml", &workbook); err != nil {
+		return "", err
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", fmt.Errorf("xlsx workbook has no sheets")
+	}
+
+	index := 0
+	if want != "" {
+		index = -1
+		for i, s := range workbook.Sheets {
+			if strings.EqualFold(s.Name, want) {
+				index = i
+			}
+		}
+		if n, err := strconv.Atoi(want); index < 0 && err == nil && n >= 1 && n <= len(workbook.Sheets) {
+			index = n - 1
+		}
+		if index < 0 {
+			return "", fmt.Errorf("xlsx sheet not found: %s", want)
+		}
+	}
+
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodeZipXML(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return "", err
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID == workbook.Sheets[index].RID {
+			if strings.HasPrefix(rel.Target, "/") {
+				return strings.TrimPrefix(rel.Target, "/"), nil
+			}
+			return path.Join("xl", rel.Target), nil
+		}
+	}
+	return fmt.Sprintf("xl/worksheets/sheet%d.xml", index+1), nil
+}
+
+func xlsxSharedStrings(files map[string]*zip.File) ([]string, error) {
+	if _, ok := files["xl/sharedStrings.xml"]; !ok {
+		return nil, nil
+	}
+	var sst struct {
+		Items []struct {
+			Text string   `xml:"t"`
+			Runs []string `xml:"r>t"`
+		} `xml:"si"`
+	}
+	if err := decodeZipXML(files, "xl/sharedStrings.xml", &sst); err != nil {
+		return nil, err
+	}
+	shared := make([]string, len(sst.Items))
+	for i, si := range sst.Items {
+		shared[i] = si.Text + strings.Join(si.Runs, "")
+	}
+	return shared, nil
+}
+
+// xlsxDateStyles reports, per cell style index, whether numbers in that style
+// are dates. Excel stores dates as serial day numbers and only the number
+// format tells them apart.
+func xlsxDateStyles(files map[string]*zip.File) ([]bool, error) {
+	if _, ok := files["xl/styles.xml"]; !ok {
+		return nil, nil
+	}
+	var styles struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		CellXfs []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if err := decodeZipXML(files, "xl/styles.xml", &styles); err != nil {
+		return nil, err
+	}
+
+	custom := make(map[int]bool)
+	for _, f := range styles.NumFmts {
+		custom[f.ID] = isDateFormatCode(f.Code)
+	}
+	dates := make([]bool, len(styles.CellXfs))
+	for i, xf := range styles.CellXfs {
+		id := xf.NumFmtID
+		dates[i] = (id >= 14 && id <= 22) || (id >= 45 && id <= 47) || custom[id]
+	}
+	return dates, nil
+}
+
+// isDateFormatCode looks for date tokens outside quoted literals and brackets.
+func isDateFormatCode(code string) bool {
+	inQuote, inBracket := false, false
+	for _, r := range strings.ToLower(code) {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case r == '[':
+			inBracket = true
+		case r == ']':
+			inBracket = false
+		case inBracket:
+		case r == 'y', r == 'd', r == 'm':
+			return true
+		}
+	}
+	return false
+}
+
+func decodeZipXML(files map[string]*zip.File, name string, v any) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("invalid xlsx file: missing %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
+}
+
+// xlsxRowReader streams <row> elements of a worksheet as string records.
+type xlsxRowReader struct {
+	sheet      io.Closer
+	decoder    *xml.Decoder
+	shared     []string
+	dateStyles []bool
+	row        int
+}
+
+func (x *xlsxRowReader) Close() error { return x.sheet.Close() }
+
+func (x *xlsxRowReader) Line() int { return x.row }
+
+type xlsxCell struct {
+	Ref    string `xml:"r,attr"`
+	Type   string `xml:"t,attr"`
+	Style  int    `xml:"s,attr"`
+	Value  string `xml:"v"`
+	Inline string `xml:"is>t"`
+}
+
+func (x *xlsxRowReader) Read() ([]string, error) {
+	for {
+		token, err := x.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+
+		var row struct {
+			Ref   int        `xml:"r,attr"`
+			Cells []xlsxCell `xml:"c"`
+		}
+		if err := x.decoder.DecodeElement(&row, &start); err != nil {
+			return nil, err
+		}
+		x.row++
+		if row.Ref > 0 {
+			x.row = row.Ref
+		}
+
+		var record []string
+		for i, c := range row.Cells {
+			col := i
+			if c.Ref != "" {
+				col = xlsxColumn(c.Ref)
+			}
+			for len(record) < col {
+				record = append(record, "")
+			}
+			record = append(record[:col], x.value(c))
+		}
+		return record, nil
+	}
+}
+
+func (x *xlsxRowReader) value(c xlsxCell) string {
+	switch c.Type {
+	case "s":
+		i, err := strconv.Atoi(c.Value)
+		if err != nil || i < 0 || i >= len(x.shared) {
+			return ""
+		}
+		return x.shared[i]
+	case "inlineStr":
+		return c.Inline
+	case "str", "b", "e":
+		return c.Value
+	}
+
+	if c.Style >= 0 && c.Style < len(x.dateStyles) && x.dateStyles[c.Style] {
+		if serial, err := strconv.ParseFloat(c.Value, 64); err == nil {
+			t := excelSerialDate(serial)
+			if t.Equal(calendarDay(t)) {
+				return t.Format("2006-01-02")
+			}
+			return t.Format("2006-01-02 15:04:05")
+		}
+	}
+	return c.Value
+}
+
+// xlsxColumn turns the letters of a cell reference ("AB12") into a 0-based index.
+func xlsxColumn(ref string) int {
+	col := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+	}
+	return col - 1
+}
+
+// excelSerialDate converts a 1900-system serial (days since 1899-12-30) to a date.
+func excelSerialDate(serial float64) time.Time {
+	days := math.Floor(serial)
+	seconds := math.Round((serial - days) * 86400)
+	return time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).
+		AddDate(0, 0, int(days)).
+		Add(time.Duration(seconds) * time.Second)
+}
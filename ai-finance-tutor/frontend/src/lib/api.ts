@@ -3,13 +3,19 @@ import { dashboard, expenses, insights, loading } from './stores';
 
 const API_URL = 'http://localhost:8000';
 
-export async function uploadCSV(file: File) {
+// With merge, the file's transactions are added to the current data and
+// ones already uploaded are skipped, instead of replacing everything.
+// Several files (a bank statement, a card statement, a wallet export) are
+// analysed together, each transaction tagged with its source account.
+export async function uploadCSV(files: File | File[], merge = false) {
     loading.set(true);
     const formData = new FormData();
-    formData.append('file', file);
+    for (const file of [files].flat()) {
+        formData.append('file', file);
+    }
 
     try {
-        const response = await fetch(`${API_URL}/upload`, {
+        const response = await fetch(`${API_URL}/upload${merge ? '?mode=merge' : ''}`, {
             method: 'POST',
             body: formData,
         });
@@ -24,10 +30,16 @@ export async function uploadCSV(file: File) {
     }
 }
 
-export async function getSampleData() {
+// persona is "student", "young_professional" or "family". The data ends with
+// last month, so the same seed gives the same transactions only within a month.
+export async function getSampleData(persona = '', months = 0, seed = 0) {
     loading.set(true);
+    const params = new URLSearchParams();
+    if (persona) params.set('persona', persona);
+    if (months) params.set('months', String(months));
+    if (seed) params.set('seed', String(seed));
     try {
-        const response = await fetch(`${API_URL}/sample-data`);
+        const response = await fetch(`${API_URL}/sample-data?${params}`);
         if (!response.ok) throw new Error('Failed to get sample data');
         const data = await response.json();
         updateStore(data);
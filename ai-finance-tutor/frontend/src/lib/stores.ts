@@ -1,4 +1,4 @@
-import { writable } from 'svelte/store';
+import { derived, writable } from 'svelte/store';
 
 export const dashboard = writable<any>(null);
 export const expenses = writable<any[]>([]);
@@ -6,3 +6,23 @@ export const insights = writable<any[]>([]);
 export const aiMode = writable<'polite' | 'savage'>('polite');
 export const loading = writable<boolean>(false);
 export const persona = writable<any>(null);
+
+// Symbol of the base currency every amount on the dashboard is in, e.g. "₹" or "$".
+export const currencySymbol = derived(dashboard, ($dashboard) => {
+    const currency = $dashboard?.base_currency || 'INR';
+    try {
+        const parts = new Intl.NumberFormat('en-IN', { style: 'currency', currency }).formatToParts(0);
+        return parts.find((p) => p.type === 'currency')?.value ?? currency;
+    } catch {
+        return currency;
+    }
+});
+
+// Formats an amount in any ISO currency, e.g. "$12.50" or "AED 300.00".
+export function formatAmount(amount: number, currency: string): string {
+    try {
+        return new Intl.NumberFormat('en-IN', { style: 'currency', currency }).format(amount);
+    } catch {
+        return `${currency} ${amount.toFixed(2)}`;
+    }
+}